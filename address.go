@@ -0,0 +1,183 @@
+package terralib
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+)
+
+// Resource modes
+const (
+	ModeManaged string = "managed"
+	ModeData    string = "data"
+)
+
+// ModuleStep represents a module call in a module path, with its instance
+// key if it uses count or for_each
+type ModuleStep struct {
+	Name string
+	Key  interface{}
+}
+
+// ResourceAddress represents the address of a resource or resource instance
+// like module.net["eu"].aws_subnet.private[2]. Keys are nil, an int or a string
+type ResourceAddress struct {
+	Module []ModuleStep
+	Mode   string
+	Type   string
+	Name   string
+	Key    interface{}
+}
+
+// ParseResourceAddress parses a resource address
+func ParseResourceAddress(address string) (ResourceAddress, error) {
+	a := ResourceAddress{Mode: ModeManaged}
+	module, rest, err := parseModulePath(address)
+	if err != nil {
+		return a, err
+	}
+	if len(module) > 0 {
+		if rest == "" {
+			return a, errors.New("Invalid resource address \"" + address + "\": no resource after the module path")
+		}
+		a.Module = module
+		rest = rest[1:]
+	}
+	if strings.HasPrefix(rest, "data.") {
+		a.Mode = ModeData
+		rest = strings.TrimPrefix(rest, "data.")
+	}
+	a.Type, rest = splitName(rest)
+	if !strings.HasPrefix(rest, ".") {
+		return a, errors.New("Invalid resource address \"" + address + "\": no resource name")
+	}
+	a.Name, rest = splitName(rest[1:])
+	if a.Key, rest, err = parseKey(rest); err != nil {
+		return a, err
+	}
+	if a.Type == "" || a.Name == "" || rest != "" {
+		return a, errors.New("Invalid resource address \"" + address + "\"")
+	}
+	return a, nil
+}
+
+// String returns the address in the format used by terraform
+func (a ResourceAddress) String() string {
+	var b strings.Builder
+	if module := a.ModuleAddress(); module != "" {
+		b.WriteString(module)
+		b.WriteString(".")
+	}
+	if a.Mode == ModeData {
+		b.WriteString("data.")
+	}
+	b.WriteString(a.Type)
+	b.WriteString(".")
+	b.WriteString(a.Name)
+	b.WriteString(formatKey(a.Key))
+	return b.String()
+}
+
+// ModuleAddress returns the address of the module of the resource, empty for
+// the root module
+func (a ResourceAddress) ModuleAddress() string {
+	var steps []string
+	for _, step := range a.Module {
+		steps = append(steps, "module."+step.Name+formatKey(step.Key))
+	}
+	return strings.Join(steps, ".")
+}
+
+// Resource returns the address of the resource, without the instance key
+func (a ResourceAddress) Resource() ResourceAddress {
+	a.Key = nil
+	return a
+}
+
+// ParseModuleAddress parses the address of a module instance, like
+// module.net["eu"]
+func ParseModuleAddress(address string) ([]ModuleStep, error) {
+	module, rest, err := parseModulePath(address)
+	if err != nil {
+		return nil, err
+	}
+	if len(module) == 0 || rest != "" {
+		return nil, errors.New("Invalid module address \"" + address + "\"")
+	}
+	return module, nil
+}
+
+// parseModulePath parses the module steps at the start of an address. The
+// rest of the address starts with a dot, or else is empty
+func parseModulePath(address string) ([]ModuleStep, string, error) {
+	var module []ModuleStep
+	rest := address
+	for strings.HasPrefix(rest, "module.") {
+		var step ModuleStep
+		var err error
+		step.Name, rest = splitName(strings.TrimPrefix(rest, "module."))
+		if step.Key, rest, err = parseKey(rest); err != nil {
+			return module, rest, err
+		}
+		if rest != "" && !strings.HasPrefix(rest, ".") {
+			return module, rest, errors.New("Invalid address \"" + address + "\"")
+		}
+		module = append(module, step)
+		if rest == "" || !strings.HasPrefix(rest, ".module.") {
+			break
+		}
+		rest = rest[1:]
+	}
+	return module, rest, nil
+}
+
+// splitName splits an identifier from the start of s
+func splitName(s string) (string, string) {
+	i := strings.IndexAny(s, ".[")
+	if i < 0 {
+		return s, ""
+	}
+	return s[:i], s[i:]
+}
+
+// parseKey parses an instance key like [2] or ["eu"] from the start of s
+func parseKey(s string) (interface{}, string, error) {
+	if !strings.HasPrefix(s, "[") {
+		return nil, s, nil
+	}
+	if strings.HasPrefix(s, "[\"") {
+		for i := 2; i < len(s); i++ {
+			if s[i] == '\\' {
+				i++
+				continue
+			}
+			if s[i] == '"' {
+				key, err := strconv.Unquote(s[1 : i+1])
+				if err != nil || i+1 >= len(s) || s[i+1] != ']' {
+					return nil, s, errors.New("Invalid instance key " + s)
+				}
+				return key, s[i+2:], nil
+			}
+		}
+		return nil, s, errors.New("Invalid instance key " + s)
+	}
+	end := strings.Index(s, "]")
+	if end < 0 {
+		return nil, s, errors.New("Invalid instance key " + s)
+	}
+	key, err := strconv.Atoi(s[1:end])
+	if err != nil {
+		return nil, s, errors.New("Invalid instance key " + s[:end+1])
+	}
+	return key, s[end+1:], nil
+}
+
+func formatKey(key interface{}) string {
+	switch k := key.(type) {
+	case int:
+		return "[" + strconv.Itoa(k) + "]"
+	case string:
+		return "[" + strconv.Quote(k) + "]"
+	}
+	return ""
+}
@@ -0,0 +1,69 @@
+package terralib
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestParseResourceAddress(t *testing.T) {
+	tests := map[string]ResourceAddress{
+		"aws_instance.web": {
+			Mode: ModeManaged,
+			Type: "aws_instance",
+			Name: "web",
+		},
+		"data.aws_ami.ubuntu": {
+			Mode: ModeData,
+			Type: "aws_ami",
+			Name: "ubuntu",
+		},
+		`module.net["eu"].aws_subnet.private[2]`: {
+			Module: []ModuleStep{{Name: "net", Key: "eu"}},
+			Mode:   ModeManaged,
+			Type:   "aws_subnet",
+			Name:   "private",
+			Key:    2,
+		},
+		`module.app.module.db[0].aws_db_instance.main["a.b[c]"]`: {
+			Module: []ModuleStep{{Name: "app"}, {Name: "db", Key: 0}},
+			Mode:   ModeManaged,
+			Type:   "aws_db_instance",
+			Name:   "main",
+			Key:    "a.b[c]",
+		},
+	}
+	for address, expected := range tests {
+		got, err := ParseResourceAddress(address)
+		if err != nil {
+			t.Errorf("%s: %v", address, err)
+			continue
+		}
+		if !cmp.Equal(got, expected) {
+			t.Errorf("Got: %+v, Expected: %+v", got, expected)
+		}
+		if got.String() != address {
+			t.Errorf("Got: %s, Expected: %s", got.String(), address)
+		}
+	}
+}
+
+func TestParseResourceAddressInvalid(t *testing.T) {
+	for _, address := range []string{"aws_instance", "module.net", "aws_instance.web[x]", `aws_instance.web["a]`, "aws_instance.web.id"} {
+		if _, err := ParseResourceAddress(address); err == nil {
+			t.Errorf("Expected an error parsing %q", address)
+		}
+	}
+}
+
+func TestResourceAddressModule(t *testing.T) {
+	a, _ := ParseResourceAddress(`module.net["eu"].module.subnets.aws_subnet.private[2]`)
+	expected := `module.net["eu"].module.subnets`
+	if got := a.ModuleAddress(); got != expected {
+		t.Errorf("Got: %s, Expected: %s", got, expected)
+	}
+	expected = `module.net["eu"].module.subnets.aws_subnet.private`
+	if got := a.Resource().String(); got != expected {
+		t.Errorf("Got: %s, Expected: %s", got, expected)
+	}
+}
@@ -1,69 +1,175 @@
 package terralib
 
 import (
-	"os/exec"
-	"regexp"
-	"strings"
+	"fmt"
 )
 
 // Exported error codes
 const (
-	ErrApplyDefault string = "errApplyDefault"
+	ErrSavedPlanStale             string = "errSavedPlanStale"
+	ErrProviderInconsistentResult string = "errProviderInconsistentResult"
+	ErrResourceAlreadyExists      string = "errResourceAlreadyExists"
+	ErrTimeoutWhileWaiting        string = "errTimeoutWhileWaiting"
+	ErrInsufficientPermissions    string = "errInsufficientPermissions"
+	ErrQuotaExceeded              string = "errQuotaExceeded"
+	ErrFailedToPersistState       string = "errFailedToPersistState"
+	ErrApplyDefault               string = "errApplyDefault"
 )
 
 // ApplyOutput represents the output of the apply command
 type ApplyOutput struct {
-	Raw string
+	Raw      string
+	Attempts []Attempt
 }
 
-var applyErrors = map[string]string{}
+var applyClassifiers = []Classifier{
+	NewClassifier(ErrSavedPlanStale, `Saved plan is stale`, CommandApply),
+	NewClassifier(ErrProviderInconsistentResult, `(?P<reason>Provider produced inconsistent result after apply)\s+`+
+		`When applying changes to (?P<address>[^,\s]+),\s+provider\s+"?(?P<provider>[^"\s]+)"?`, CommandApply, CommandDestroy),
+	NewClassifier(ErrResourceAlreadyExists, `Error: (?P<reason>[^\n]*(?:already exists|AlreadyExists)[^\n]*?)\.?\n`, CommandApply, CommandDestroy),
+	NewClassifier(ErrTimeoutWhileWaiting, `Error: (?P<reason>[^\n]*timeout while waiting for [^\n]*?)\.?\n`, CommandApply, CommandDestroy),
+	NewClassifier(ErrThrottled, `Error: (?P<reason>[^\n]*(?:Throttling|ThrottlingException|RequestLimitExceeded|TooManyRequests|`+
+		`Rate exceeded|Error 429|status code: 429)[^\n]*?)\.?\n`, CommandApply, CommandDestroy),
+	NewClassifier(ErrQuotaExceeded, `Error: (?P<reason>[^\n]*(?:LimitExceeded|QuotaExceeded|(?i:quota[^\n]*exceeded))[^\n]*?)\.?\n`, CommandApply, CommandDestroy),
+	NewClassifier(ErrInsufficientPermissions, `Error: (?P<reason>[^\n]*(?:AccessDenied|UnauthorizedOperation|AuthorizationFailed|`+
+		`is not authorized to perform|Error 403|status code: 403)[^\n]*?)\.?\n`, CommandApply, CommandDestroy),
+	NewClassifier(ErrFailedToPersistState, `(?P<reason>Failed to (?:persist|save) state[^\n]*?)\.?\n`, CommandApply, CommandDestroy),
+	defaultClassifier(ErrApplyDefault, CommandApply, CommandDestroy),
+}
 
-// ApplyError represents an error on the Init command
+// ApplyError represents an error on the Apply command. Address, Provider,
+// LockID, Lock and Violations are set when the error refers to them, Variable
+// when it refers to an input variable, File and Line when it refers to an input
+// variable or the configuration, and Cycle on ErrCycle
 type ApplyError struct {
-	Reason string
-	Code   string
+	Reason     string
+	Code       string
+	Address    string
+	Provider   string
+	LockID     string
+	Lock       *LockInfo
+	Violations []Violation
+	Variable   string
+	File       string
+	Line       int
+	Cycle      *CycleError
 }
 
 func (e ApplyError) Error() string {
 	return e.Code
 }
 
-// Apply executes the 'terraform apply' command
-func (t *Terralib) Apply(options []string) (ApplyOutput, error) {
+// Apply executes the 'terraform apply' command. When Terralib.Policies is set,
+// a plan file must be given and it is not applied if it breaks a blocking policy.
+// Terralib.Variables are not passed along a plan file, which holds them already
+func (t *Terralib) Apply(options []string) (applyOutput ApplyOutput, err error) {
+	options = t.inputOptions(options)
+	if err := t.beforeCommand(CommandApply, options); err != nil {
+		return ApplyOutput{}, err
+	}
+	defer func() {
+		t.afterCommand(CommandApply, options, applyOutput, err)
+	}()
+	if t.Policies != nil {
+		if err := t.checkPolicies(options); err != nil {
+			return ApplyOutput{}, err
+		}
+	}
+	if applyPlanFile(options) != "" {
+		return t.apply(CommandApply, options, nil)
+	}
+	varOptions, env, cleanup, err := t.variableOptions()
+	defer cleanup()
+	if err != nil {
+		return ApplyOutput{}, err
+	}
+	return t.apply(CommandApply, append(varOptions, options...), env)
+}
 
-	cmdString := formatCommand("apply", options)
-	cmd := exec.Command("sh", "-c", cmdString)
-	cmd.Dir = t.ConfigPath
-	stdOutputError, _ := cmd.CombinedOutput()
-	planError := findPlanError(stdOutputError)
+// Destroy executes the 'terraform destroy' command. Its errors are those of
+// Apply. As destroy takes no plan file, it fails with
+// ErrPolicyPlanFileRequired when Policies are set: plan with -destroy and
+// -out, then apply the plan file
+func (t *Terralib) Destroy(options []string) (destroyOutput ApplyOutput, err error) {
+	options = t.inputOptions(options)
+	if err := t.beforeCommand(CommandDestroy, options); err != nil {
+		return ApplyOutput{}, err
+	}
+	defer func() {
+		t.afterCommand(CommandDestroy, options, destroyOutput, err)
+	}()
+	if t.Policies != nil {
+		return ApplyOutput{}, ApplyError{
+			Reason: "A plan file is required to evaluate policies, plan with -destroy and apply it",
+			Code:   ErrPolicyPlanFileRequired,
+		}
+	}
+	varOptions, env, cleanup, err := t.variableOptions()
+	defer cleanup()
+	if err != nil {
+		return ApplyOutput{}, err
+	}
+	return t.apply(CommandDestroy, append(varOptions, options...), env)
+}
+
+func (t *Terralib) apply(command string, options []string, env []string) (ApplyOutput, error) {
+	stdOutputError, match, attempts := t.execute(command, options, env)
+	applyError := applyErrorFrom(match, stdOutputError)
 	return ApplyOutput{
-		Raw: string(stdOutputError),
-	}, planError
+		Raw:      string(stdOutputError),
+		Attempts: attempts,
+	}, applyError
 }
 
 func findApplyError(output []byte) error {
-	var applyError ApplyError
-	for k, v := range planErrors {
-		r := regexp.MustCompile(v)
-		line := r.Find(output)
-		if line != nil {
-			applyError = ApplyError{
-				Reason: string(line),
-				Code:   k,
-			}
-			return applyError
+	return applyErrorFrom(DefaultClassifiers.Classify(CommandApply, "", output), output)
+}
+
+func applyErrorFrom(match *Match, output []byte) error {
+	if match == nil {
+		return nil
+	}
+	output = unframeDiagnostics(output)
+	block := diagnosticBlock(output, match.Reason)
+	address := match.Fields["address"]
+	if address == "" {
+		address = diagnosticAddress(block)
+	}
+	applyError := ApplyError{
+		Reason:   match.Reason,
+		Code:     match.Code,
+		Address:  address,
+		Provider: match.Fields["provider"],
+		LockID:   match.Fields["lock_id"],
+		Lock:     lockInfoFrom(match, output),
+		Cycle:    cycleFrom(match),
+	}
+	if isVariableError(match.Code) {
+		applyError.Variable = diagnosticVariable(block)
+	}
+	if isVariableError(match.Code) || configurationErrors[match.Code] {
+		applyError.File, applyError.Line = diagnosticLocation(block)
+	}
+	return applyError
+}
+
+func (t *Terralib) checkPolicies(options []string) error {
+	planFile := applyPlanFile(options)
+	if planFile == "" {
+		return ApplyError{
+			Reason: "A plan file is required to evaluate policies",
+			Code:   ErrPolicyPlanFileRequired,
 		}
 	}
-	// Get a default error or else return no error
-	r := regexp.MustCompile("Error: (.*).")
-	line := r.Find(output)
-	if line != nil {
-		reason := strings.Split(string(line), "Error: ")
-		if reason != nil {
-			return PlanError{
-				Reason: strings.TrimSuffix(reason[1], "."),
-				Code:   ErrApplyDefault,
-			}
+	_, violations, err := t.evaluatePolicies(planFile)
+	if err != nil {
+		return err
+	}
+	if blocking := Blocking(violations); len(blocking) > 0 {
+		return ApplyError{
+			Reason:     fmt.Sprintf("Plan breaks %d blocking policies", len(blocking)),
+			Code:       ErrPolicyViolation,
+			Violations: blocking,
 		}
 	}
 	return nil
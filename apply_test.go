@@ -0,0 +1,238 @@
+package terralib
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+const applyOutputErrStateLockedTest string = `
+Error: Error acquiring the state lock
+
+Error message: ConditionalCheckFailedException: The conditional request failed
+Lock Info:
+  ID:        d4d8b8b3-5a9e-a0e3-6f1c-3b2e7c0e5a11
+  Path:      terraform-state/prod/terraform.tfstate
+  Operation: OperationTypeApply
+  Who:       jenkins@ci-runner-4
+  Version:   1.5.7
+  Created:   2023-09-12 10:14:31.524872 +0000 UTC
+  Info:
+
+
+Terraform acquires a state lock to protect the state from being written
+by multiple users at the same time. Please resolve the issue above and try
+again. For most commands, you can disable locking with the "-lock=false"
+flag, but this is not recommended.
+`
+
+const applyOutputErrAcquiringStateLockTest string = `
+Error: Error acquiring the state lock: 2 errors occurred:
+	* ResourceNotFoundException: Requested resource not found
+	* ResourceNotFoundException: Requested resource not found
+
+
+
+Terraform acquires a state lock to protect the state from being written
+by multiple users at the same time. Please resolve the issue above and try
+again. For most commands, you can disable locking with the "-lock=false"
+flag, but this is not recommended.
+`
+
+const applyOutputErrSavedPlanStaleTest string = `
+Error: Saved plan is stale
+
+The given plan file can no longer be applied because the state was changed by
+another operation after the plan was created.
+`
+
+const applyOutputErrProviderInconsistentResultTest string = `
+aws_instance.web: Creating...
+
+Error: Provider produced inconsistent result after apply
+
+When applying changes to aws_instance.web, provider
+"registry.terraform.io/hashicorp/aws" produced an unexpected new value:
+Root resource was present, but now absent.
+
+This is a bug in the provider, which should be reported in the provider's own
+issue tracker.
+`
+
+const applyOutputErrResourceAlreadyExistsTest string = `
+azurerm_resource_group.example: Creating...
+
+Error: A resource with the ID "/subscriptions/0000/resourceGroups/example" already exists - to be managed via Terraform this resource needs to be imported into the State. Please see the resource documentation for "azurerm_resource_group" for more information.
+
+  with azurerm_resource_group.example,
+  on main.tf line 5, in resource "azurerm_resource_group" "example":
+   5: resource "azurerm_resource_group" "example" {
+`
+
+const applyOutputErrTimeoutWhileWaitingTest string = `
+aws_db_instance.main: Still creating... [40m0s elapsed]
+
+Error: Error waiting for DB Instance to become available: timeout while waiting for state to become 'available' (last state: 'creating', timeout: 40m0s)
+
+  on rds.tf line 12, in resource "aws_db_instance" "main":
+  12: resource "aws_db_instance" "main" {
+`
+
+const applyOutputErrInsufficientPermissionsTest string = `
+aws_s3_bucket.logs: Creating...
+
+Error: creating S3 Bucket (example-logs): AccessDenied: Access Denied
+	status code: 403, request id: 9TQ1J4YQ2C1Z5K0V
+
+  with aws_s3_bucket.logs,
+  on s3.tf line 1, in resource "aws_s3_bucket" "logs":
+   1: resource "aws_s3_bucket" "logs" {
+`
+
+const applyOutputErrQuotaExceededTest string = `
+google_compute_instance.vm: Creating...
+
+Error: Error creating instance: googleapi: Error 403: Quota 'CPUS' exceeded. Limit: 24.0 in region europe-west1., quotaExceeded
+
+  with google_compute_instance.vm,
+  on main.tf line 20, in resource "google_compute_instance" "vm":
+  20: resource "google_compute_instance" "vm" {
+`
+
+const applyOutputErrFailedToPersistStateTest string = `
+Failed to save state: failed to upload state: AccessDenied: Access Denied
+
+Error: Failed to persist state to backend
+
+The error shown above has prevented Terraform from writing the updated state to
+the configured backend.
+`
+
+func TestFindApplyErrors(t *testing.T) {
+	tests := map[string]struct {
+		output   string
+		expected ApplyError
+	}{
+		"StateLocked": {
+			output: applyOutputErrStateLockedTest,
+			expected: ApplyError{
+				Reason: "Error acquiring the state lock",
+				Code:   "errStateLocked",
+				LockID: "d4d8b8b3-5a9e-a0e3-6f1c-3b2e7c0e5a11",
+				Lock: &LockInfo{
+					ID:        "d4d8b8b3-5a9e-a0e3-6f1c-3b2e7c0e5a11",
+					Path:      "terraform-state/prod/terraform.tfstate",
+					Operation: "OperationTypeApply",
+					Who:       "jenkins@ci-runner-4",
+					Version:   "1.5.7",
+					Created:   "2023-09-12 10:14:31.524872 +0000 UTC",
+				},
+			},
+		},
+		"AcquiringStateLock": {
+			output: applyOutputErrAcquiringStateLockTest,
+			expected: ApplyError{
+				Reason: "Error acquiring the state lock: 2 errors occurred:",
+				Code:   "errAcquiringStateLock",
+			},
+		},
+		"SavedPlanStale": {
+			output: applyOutputErrSavedPlanStaleTest,
+			expected: ApplyError{
+				Reason: "Saved plan is stale",
+				Code:   "errSavedPlanStale",
+			},
+		},
+		"ProviderInconsistentResult": {
+			output: applyOutputErrProviderInconsistentResultTest,
+			expected: ApplyError{
+				Reason:   "Provider produced inconsistent result after apply",
+				Code:     "errProviderInconsistentResult",
+				Address:  "aws_instance.web",
+				Provider: "registry.terraform.io/hashicorp/aws",
+			},
+		},
+		"ResourceAlreadyExists": {
+			output: applyOutputErrResourceAlreadyExistsTest,
+			expected: ApplyError{
+				Reason: ("A resource with the ID \"/subscriptions/0000/resourceGroups/example\" already exists - " +
+					"to be managed via Terraform this resource needs to be imported into the State. " +
+					"Please see the resource documentation for \"azurerm_resource_group\" for more information"),
+				Code:    "errResourceAlreadyExists",
+				Address: "azurerm_resource_group.example",
+			},
+		},
+		"TimeoutWhileWaiting": {
+			output: applyOutputErrTimeoutWhileWaitingTest,
+			expected: ApplyError{
+				Reason: ("Error waiting for DB Instance to become available: timeout while waiting for state " +
+					"to become 'available' (last state: 'creating', timeout: 40m0s)"),
+				Code:    "errTimeoutWhileWaiting",
+				Address: "aws_db_instance.main",
+			},
+		},
+		"InsufficientPermissions": {
+			output: applyOutputErrInsufficientPermissionsTest,
+			expected: ApplyError{
+				Reason:  "creating S3 Bucket (example-logs): AccessDenied: Access Denied",
+				Code:    "errInsufficientPermissions",
+				Address: "aws_s3_bucket.logs",
+			},
+		},
+		"QuotaExceeded": {
+			output: applyOutputErrQuotaExceededTest,
+			expected: ApplyError{
+				Reason: ("Error creating instance: googleapi: Error 403: Quota 'CPUS' exceeded. " +
+					"Limit: 24.0 in region europe-west1., quotaExceeded"),
+				Code:    "errQuotaExceeded",
+				Address: "google_compute_instance.vm",
+			},
+		},
+		"FailedToPersistState": {
+			output: applyOutputErrFailedToPersistStateTest,
+			expected: ApplyError{
+				Reason: "Failed to save state: failed to upload state: AccessDenied: Access Denied",
+				Code:   "errFailedToPersistState",
+			},
+		},
+	}
+	for name, test := range tests {
+		got := findApplyError([]byte(test.output))
+		if !cmp.Equal(got, test.expected) {
+			t.Errorf("%s got: %+v, Expected: %+v", name, got, test.expected)
+		}
+	}
+}
+
+func TestFindApplyErrDefault(t *testing.T) {
+	expected := ApplyError{
+		Reason: "something wrong happened",
+		Code:   "errApplyDefault",
+	}
+	got := findApplyError([]byte(planOutputErrDefaultTest))
+	if !cmp.Equal(got, expected) {
+		t.Errorf("Got: %+v, Expected: %+v", got, expected)
+	}
+}
+
+func TestFindApplyConfigurationError(t *testing.T) {
+	output := `
+Error: Missing required argument
+
+  on main.tf line 8, in resource "aws_instance" "web":
+   8: resource "aws_instance" "web" {
+
+The argument "ami" is required, but no definition was found.
+`
+	expected := ApplyError{
+		Reason:  "Missing required argument",
+		Code:    ErrMissingRequiredArgument,
+		Address: "aws_instance.web",
+		File:    "main.tf",
+		Line:    8,
+	}
+	got := findApplyError([]byte(output))
+	if !cmp.Equal(got, expected) {
+		t.Errorf("Got: %+v, Expected: %+v", got, expected)
+	}
+}
@@ -0,0 +1,166 @@
+package terralib
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Exported error codes
+const (
+	ErrBackendConfigChanged   string = "errBackendConfigChanged"
+	ErrBackendInitRequired    string = "errBackendInitRequired"
+	ErrBackendMigrationPrompt string = "errBackendMigrationPrompt"
+	ErrBackendNotDeclared     string = "errBackendNotDeclared"
+)
+
+var backendClassifiers = []Classifier{
+	NewClassifier(ErrBackendConfigChanged, `Error: (?P<reason>Backend configuration changed[^\n]*?)\.?\n`, CommandInit),
+	NewClassifier(ErrBackendMigrationPrompt, `Error: (?P<reason>[^\n]*state migration action[^\n]*?)\.?(?:\n|$)`,
+		CommandInit),
+	NewClassifier(ErrBackendInitRequired, `Error: (?P<reason>Backend (?:re)?initialization required[^\n]*?)\.?(?:\n|$)`,
+		CommandPlan, CommandApply, CommandDestroy, CommandShow),
+}
+
+// Backend modes, which set how Init handles a backend configuration that
+// changed since the last init
+const (
+	// BackendReconfigure configures the backend ignoring the saved
+	// configuration, without migrating the state
+	BackendReconfigure string = "reconfigure"
+	// BackendMigrateState copies the state to the new backend without asking
+	BackendMigrateState string = "migrate-state"
+)
+
+// BackendConfig represents the backend configuration passed to Init
+type BackendConfig struct {
+	// Type is the backend type, like "s3". The configuration must declare it
+	// in a backend block, which Init checks when Type is set
+	Type string
+	// Config holds the backend arguments, written to a temporary backend
+	// configuration file so secrets are not passed as options
+	Config map[string]interface{}
+	// Files are backend configuration files passed along with Config
+	Files []string
+	// Mode is BackendReconfigure, BackendMigrateState or empty for none
+	Mode string
+}
+
+// backendOptions writes the files of the backend configuration and returns
+// the init options using them, along with a function removing the files
+func (t *Terralib) backendOptions() ([]string, func(), error) {
+	var options []string
+	var files []string
+	cleanup := func() {
+		for _, file := range files {
+			os.Remove(file)
+		}
+	}
+	b := t.Backend
+	if b.Type != "" {
+		declared, err := t.declaresBackend(b.Type)
+		if err != nil {
+			return nil, cleanup, err
+		}
+		if !declared {
+			return nil, cleanup, InitError{
+				Reason: fmt.Sprintf("The configuration declares no backend %q", b.Type),
+				Code:   ErrBackendNotDeclared,
+			}
+		}
+	}
+	for _, file := range b.Files {
+		options = append(options, "-backend-config="+file)
+	}
+	if len(b.Config) > 0 {
+		f, err := ioutil.TempFile("", "terralib-*.tfbackend")
+		if err != nil {
+			return nil, cleanup, err
+		}
+		files = append(files, f.Name())
+		content, err := encodeBackendConfig(b.Config)
+		if err == nil {
+			_, err = f.Write(content)
+		}
+		if closeErr := f.Close(); err == nil {
+			err = closeErr
+		}
+		if err != nil {
+			return nil, cleanup, err
+		}
+		options = append(options, "-backend-config="+f.Name())
+	}
+	switch b.Mode {
+	case BackendReconfigure:
+		options = append(options, "-reconfigure")
+	case BackendMigrateState:
+		options = append(options, "-force-copy")
+	case "":
+	default:
+		return nil, cleanup, fmt.Errorf("Unknown backend mode %q", b.Mode)
+	}
+	return options, cleanup, nil
+}
+
+// declaresBackend tells whether a configuration file of the root module has
+// a backend block of the given type
+func (t *Terralib) declaresBackend(backendType string) (bool, error) {
+	block := regexp.MustCompile(`(?m)^\s*backend\s+"` + regexp.QuoteMeta(backendType) + `"\s*\{`)
+	jsonBlock := regexp.MustCompile(`"backend"\s*:\s*\{\s*"` + regexp.QuoteMeta(backendType) + `"`)
+	for _, pattern := range []string{"*.tf", "*.tf.json"} {
+		files, err := filepath.Glob(t.path(pattern))
+		if err != nil {
+			return false, err
+		}
+		for _, file := range files {
+			content, err := ioutil.ReadFile(file)
+			if err != nil {
+				return false, err
+			}
+			if block.Match(content) || jsonBlock.Match(content) {
+				return true, nil
+			}
+		}
+	}
+	return false, nil
+}
+
+// encodeBackendConfig writes backend arguments as HCL attributes. Strings are
+// quoted and escaped, other values are written as JSON, which HCL accepts
+func encodeBackendConfig(config map[string]interface{}) ([]byte, error) {
+	keys := make([]string, 0, len(config))
+	for key := range config {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	var b bytes.Buffer
+	for _, key := range keys {
+		var value string
+		switch v := config[key].(type) {
+		case string:
+			value = hclString(v)
+		default:
+			encoded, err := json.Marshal(v)
+			if err != nil {
+				return nil, fmt.Errorf("Invalid value of backend argument %s: %v", key, err)
+			}
+			value = string(encoded)
+		}
+		fmt.Fprintf(&b, "%s = %s\n", key, value)
+	}
+	return b.Bytes(), nil
+}
+
+// hclString quotes a string, escaping template sequences
+func hclString(s string) string {
+	quoted := strconv.Quote(s)
+	quoted = strings.Replace(quoted, "${", "$${", -1)
+	return strings.Replace(quoted, "%{", "%%{", -1)
+}
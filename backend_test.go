@@ -0,0 +1,136 @@
+package terralib
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestEncodeBackendConfig(t *testing.T) {
+	got, err := encodeBackendConfig(map[string]interface{}{
+		"bucket":         "acme-state",
+		"key":            "prod/${env}.tfstate",
+		"encrypt":        true,
+		"max_retries":    5,
+		"allowed_ids":    []string{"123", "456"},
+		"assume_role":    map[string]string{"role_arn": "arn:aws:iam::123:role/state"},
+		"dynamodb_table": "terraform-locks",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := `allowed_ids = ["123","456"]
+assume_role = {"role_arn":"arn:aws:iam::123:role/state"}
+bucket = "acme-state"
+dynamodb_table = "terraform-locks"
+encrypt = true
+key = "prod/$${env}.tfstate"
+max_retries = 5
+`
+	if string(got) != expected {
+		t.Errorf("Got:\n%s\nExpected:\n%s", got, expected)
+	}
+}
+
+func TestInitBackend(t *testing.T) {
+	dir, err := ioutil.TempDir("", "terralib")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	main := "terraform {\n  backend \"s3\" {}\n}\n"
+	if err := ioutil.WriteFile(filepath.Join(dir, "main.tf"), []byte(main), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	var args []string
+	var config string
+	var configMode os.FileMode
+	tf := Terralib{
+		ConfigPath: dir,
+		Backend: &BackendConfig{
+			Type:   "s3",
+			Config: map[string]interface{}{"bucket": "acme-state", "secret_key": "s3cr3t"},
+			Files:  []string{"prod.tfbackend"},
+			Mode:   BackendMigrateState,
+		},
+		Executor: executorFunc(func(ctx context.Context, inv Invocation) (Result, error) {
+			args = inv.Args
+			configFile := strings.TrimPrefix(inv.Args[2], "-backend-config=")
+			data, _ := ioutil.ReadFile(configFile)
+			config = string(data)
+			if info, err := os.Stat(configFile); err == nil {
+				configMode = info.Mode()
+			}
+			return Result{Output: []byte("Successfully configured the backend \"s3\"!")}, nil
+		}),
+	}
+	output, err := tf.Init([]string{"-no-color"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(args) != 6 || args[1] != "-backend-config=prod.tfbackend" || !strings.HasSuffix(args[2], ".tfbackend") ||
+		!cmp.Equal(args[3:], []string{"-force-copy", "-input=false", "-no-color"}) {
+		t.Errorf("Got: %v, Expected the backend options", args)
+	}
+	if expected := "bucket = \"acme-state\"\nsecret_key = \"s3cr3t\"\n"; config != expected {
+		t.Errorf("Got: %q, Expected: %q", config, expected)
+	}
+	if configMode != 0600 {
+		t.Errorf("Got: %v, Expected the backend configuration file to be private", configMode)
+	}
+	if output.Backend != "s3" {
+		t.Errorf("Got: %q, Expected: s3", output.Backend)
+	}
+	files, _ := filepath.Glob(filepath.Join(dir, "*"))
+	if expected := []string{filepath.Join(dir, "main.tf")}; !cmp.Equal(files, expected) {
+		t.Errorf("Got: %v, Expected: %v", files, expected)
+	}
+	if _, err := os.Stat(strings.TrimPrefix(args[2], "-backend-config=")); !os.IsNotExist(err) {
+		t.Errorf("Expected the backend configuration file to be removed")
+	}
+}
+
+func TestInitBackendNotDeclared(t *testing.T) {
+	dir, err := ioutil.TempDir("", "terralib")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	main := `{"terraform": {"backend": {"gcs": {}}}}`
+	if err := ioutil.WriteFile(filepath.Join(dir, "main.tf.json"), []byte(main), 0644); err != nil {
+		t.Fatal(err)
+	}
+	executed := false
+	tf := Terralib{
+		ConfigPath: dir,
+		Backend:    &BackendConfig{Type: "s3"},
+		Executor: executorFunc(func(ctx context.Context, inv Invocation) (Result, error) {
+			executed = true
+			return Result{}, nil
+		}),
+	}
+	_, err = tf.Init(nil)
+	if initErr, ok := err.(InitError); !ok || initErr.Code != ErrBackendNotDeclared {
+		t.Errorf("Got: %v, Expected: %s", err, ErrBackendNotDeclared)
+	}
+	if executed {
+		t.Errorf("Init ran terraform")
+	}
+	tf.Backend.Type = "gcs"
+	if _, err := tf.Init(nil); err != nil || !executed {
+		t.Errorf("Got: %v, Expected the declared backend to be initialized", err)
+	}
+}
+
+func TestInitBackendUnknownMode(t *testing.T) {
+	tf := Terralib{Backend: &BackendConfig{Mode: "upgrade"}}
+	if _, err := tf.Init(nil); err == nil {
+		t.Errorf("Expected an error with an unknown backend mode")
+	}
+}
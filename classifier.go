@@ -0,0 +1,213 @@
+package terralib
+
+import (
+	"errors"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// fallbackPriority is used by the default error of each command, so any
+// other classifier is tried first
+const fallbackPriority int = -1000
+
+// DefaultClassifiers is the registry used by Terralib when no other is set
+var DefaultClassifiers = newDefaultClassifiers()
+
+// Classifier maps a pattern found in the terraform output to an error code
+type Classifier struct {
+	// Code is the error code returned when the pattern matches
+	Code string
+	// Pattern is matched against the combined output of the command. Named
+	// groups are returned in Match.Fields, and a group named "reason"
+	// replaces the whole match as the reason of the error
+	Pattern *regexp.Regexp
+	// Priority sets the order in which classifiers are tried, higher first.
+	// Classifiers with the same priority are tried in registration order
+	Priority int
+	// Commands limits the classifier to the given commands. Empty means all
+	Commands []string
+	// MinVersion and MaxVersion limit the classifier to a range of terraform
+	// versions, both inclusive. Empty means no limit
+	MinVersion string
+	MaxVersion string
+}
+
+// Match represents an error found in the output of a command
+type Match struct {
+	Code   string
+	Reason string
+	Fields map[string]string
+}
+
+// ClassifierRegistry holds an ordered set of classifiers
+type ClassifierRegistry struct {
+	mu          sync.RWMutex
+	classifiers []Classifier
+}
+
+// NewClassifier returns a classifier for the given commands, compiling the pattern.
+// It panics if the pattern is not a valid regular expression
+func NewClassifier(code string, pattern string, commands ...string) Classifier {
+	return Classifier{
+		Code:     code,
+		Pattern:  regexp.MustCompile(pattern),
+		Commands: commands,
+	}
+}
+
+// NewClassifierRegistry returns an empty registry
+func NewClassifierRegistry() *ClassifierRegistry {
+	return &ClassifierRegistry{}
+}
+
+func newDefaultClassifiers() *ClassifierRegistry {
+	r := NewClassifierRegistry()
+	r.MustRegister(initClassifiers...)
+	r.MustRegister(backendClassifiers...)
+	r.MustRegister(lockClassifiers...)
+	r.MustRegister(variableClassifiers...)
+	r.MustRegister(planClassifiers...)
+	r.MustRegister(applyClassifiers...)
+	r.MustRegister(showClassifiers...)
+	r.MustRegister(validateClassifiers...)
+	r.MustRegister(providersLockClassifiers...)
+	r.MustRegister(graphClassifiers...)
+	r.MustRegister(stateClassifiers...)
+	return r
+}
+
+// Register adds classifiers to the registry
+func (r *ClassifierRegistry) Register(classifiers ...Classifier) error {
+	for _, c := range classifiers {
+		if c.Code == "" {
+			return errors.New("Classifier has no code")
+		}
+		if c.Pattern == nil {
+			return errors.New("Classifier " + c.Code + " has no pattern")
+		}
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.classifiers = append(r.classifiers, classifiers...)
+	sort.SliceStable(r.classifiers, func(i, j int) bool {
+		return r.classifiers[i].Priority > r.classifiers[j].Priority
+	})
+	return nil
+}
+
+// MustRegister is like Register but panics if a classifier is not valid
+func (r *ClassifierRegistry) MustRegister(classifiers ...Classifier) {
+	if err := r.Register(classifiers...); err != nil {
+		panic(err)
+	}
+}
+
+// Classifiers returns the classifiers in the order they are tried
+func (r *ClassifierRegistry) Classifiers() []Classifier {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return append([]Classifier(nil), r.classifiers...)
+}
+
+// Clone returns a copy of the registry, so it can be extended without
+// changing the original
+func (r *ClassifierRegistry) Clone() *ClassifierRegistry {
+	return &ClassifierRegistry{classifiers: r.Classifiers()}
+}
+
+// Classify returns the first match for the output of a command, or nil if no
+// classifier matches. An empty version matches classifiers of every version
+func (r *ClassifierRegistry) Classify(command string, version string, output []byte) *Match {
+	output = unframeDiagnostics(output)
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	for _, c := range r.classifiers {
+		if !c.appliesTo(command, version) {
+			continue
+		}
+		loc := c.Pattern.FindSubmatchIndex(output)
+		if loc == nil {
+			continue
+		}
+		match := &Match{
+			Code:   c.Code,
+			Reason: string(output[loc[0]:loc[1]]),
+			Fields: map[string]string{},
+		}
+		for i, name := range c.Pattern.SubexpNames() {
+			if name == "" || loc[2*i] < 0 {
+				continue
+			}
+			match.Fields[name] = string(output[loc[2*i]:loc[2*i+1]])
+		}
+		if reason, ok := match.Fields["reason"]; ok {
+			match.Reason = reason
+		}
+		return match
+	}
+	return nil
+}
+
+func (c Classifier) appliesTo(command string, version string) bool {
+	if len(c.Commands) > 0 {
+		found := false
+		for _, cmd := range c.Commands {
+			if cmd == command {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	if version == "" {
+		return true
+	}
+	if c.MinVersion != "" && compareVersions(version, c.MinVersion) < 0 {
+		return false
+	}
+	if c.MaxVersion != "" && compareVersions(version, c.MaxVersion) > 0 {
+		return false
+	}
+	return true
+}
+
+// compareVersions compares two terraform versions like "0.12.24" or "v1.5.0",
+// ignoring pre-release suffixes
+func compareVersions(a string, b string) int {
+	pa := versionParts(a)
+	pb := versionParts(b)
+	for i := 0; i < 3; i++ {
+		if pa[i] != pb[i] {
+			if pa[i] < pb[i] {
+				return -1
+			}
+			return 1
+		}
+	}
+	return 0
+}
+
+func versionParts(version string) [3]int {
+	var parts [3]int
+	version = strings.TrimPrefix(version, "v")
+	if i := strings.IndexAny(version, "-+"); i >= 0 {
+		version = version[:i]
+	}
+	for i, p := range strings.SplitN(version, ".", 3) {
+		parts[i], _ = strconv.Atoi(p)
+	}
+	return parts
+}
+
+// defaultClassifier returns the classifier for any "Error: " line not matched
+// by a more specific classifier of the commands
+func defaultClassifier(code string, commands ...string) Classifier {
+	c := NewClassifier(code, `Error: (?P<reason>[^\n]*?)\.?(?:\n|$)`, commands...)
+	c.Priority = fallbackPriority
+	return c
+}
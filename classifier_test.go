@@ -0,0 +1,65 @@
+package terralib
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+const classifierOutputTest string = `
+Error: Error creating bucket: BucketAlreadyExists: bucket "example" is taken.
+`
+
+func TestClassifyPriority(t *testing.T) {
+	r := DefaultClassifiers.Clone()
+	c := NewClassifier("errBucketTaken", `bucket "(?P<bucket>.*)" is taken`, CommandApply)
+	c.Priority = 10
+	r.MustRegister(c)
+	expected := &Match{
+		Code:   "errBucketTaken",
+		Reason: "bucket \"example\" is taken",
+		Fields: map[string]string{"bucket": "example"},
+	}
+	got := r.Classify(CommandApply, "", []byte(classifierOutputTest))
+	if !cmp.Equal(got, expected) {
+		t.Errorf("Got: %+v, Expected: %+v", got, expected)
+	}
+	// The original registry is not changed
+	got = DefaultClassifiers.Classify(CommandApply, "", []byte(classifierOutputTest))
+	if got == nil || got.Code != ErrResourceAlreadyExists {
+		t.Errorf("Got: %+v, Expected code: %s", got, ErrResourceAlreadyExists)
+	}
+}
+
+func TestClassifyCommandScope(t *testing.T) {
+	got := DefaultClassifiers.Classify(CommandInit, "", []byte(classifierOutputTest))
+	if got != nil {
+		t.Errorf("Got: %+v, Expected: nil", got)
+	}
+}
+
+func TestClassifyVersionScope(t *testing.T) {
+	r := NewClassifierRegistry()
+	c := NewClassifier("errBucketTaken", "BucketAlreadyExists")
+	c.MinVersion = "0.13.0"
+	r.MustRegister(c)
+	tests := map[string]bool{
+		"":        true,
+		"0.12.24": false,
+		"0.13.0":  true,
+		"v1.5.7":  true,
+	}
+	for version, expected := range tests {
+		got := r.Classify(CommandApply, version, []byte(classifierOutputTest)) != nil
+		if got != expected {
+			t.Errorf("Version %q got: %v, Expected: %v", version, got, expected)
+		}
+	}
+}
+
+func TestRegisterInvalidClassifier(t *testing.T) {
+	r := NewClassifierRegistry()
+	if err := r.Register(Classifier{Code: "errNoPattern"}); err == nil {
+		t.Errorf("Expected an error registering a classifier without pattern")
+	}
+}
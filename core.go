@@ -1,10 +1,171 @@
 package terralib
 
 import (
+	"context"
 	"fmt"
 	"strings"
+	"time"
+)
+
+// Command names, used to scope error classifiers and retry policies
+const (
+	CommandInit          string = "init"
+	CommandPlan          string = "plan"
+	CommandApply         string = "apply"
+	CommandDestroy       string = "destroy"
+	CommandShow          string = "show"
+	CommandValidate      string = "validate"
+	CommandProvidersLock string = "providers lock"
+	CommandGraph         string = "graph"
+	CommandStatePull     string = "state pull"
+	CommandStatePush     string = "state push"
+	CommandForceUnlock   string = "force-unlock"
 )
 
 func formatCommand(cmd string, options []string) string {
 	return fmt.Sprintf("terraform %s %s", cmd, strings.Join(options, " "))
 }
+
+// run executes a terraform command on the configuration path, with env added
+// to the environment, and returns its result, with exit code -1 along with the
+// error of the executor if it could not be run. Questions asked by terraform
+// are answered by Terralib.Prompter, or else stop the command with a
+// promptError
+func (t *Terralib) run(command string, options []string, env []string) (Result, error) {
+	executor := t.Executor
+	if executor == nil {
+		executor = ShellExecutor{}
+	}
+	ctx, cancel := context.WithCancel(t.context())
+	defer cancel()
+	stdin := newAnswers()
+	defer stdin.Close()
+	watcher := &promptWatcher{
+		prompter: t.Prompter,
+		stdin:    stdin,
+		cancel:   cancel,
+	}
+	result, err := executor.Execute(ctx, Invocation{
+		Args:   append(strings.Fields(command), options...),
+		Dir:    t.ConfigPath,
+		Env:    env,
+		Stdin:  stdin,
+		Stdout: watcher,
+	})
+	if promptErr := watcher.err(); promptErr != nil {
+		err = promptErr
+	}
+	if err != nil {
+		result.ExitCode = -1
+	}
+	return result, err
+}
+
+// DetectVersion runs 'terraform version' and sets TerraformVersion to the
+// version of the terraform binary, which scopes the classifiers and is logged
+func (t *Terralib) DetectVersion() (string, error) {
+	result, err := t.run("version", nil, nil)
+	if err != nil {
+		return "", err
+	}
+	var version string
+	if _, err := fmt.Sscanf(string(result.Output), "Terraform v%s", &version); err != nil {
+		return "", fmt.Errorf("Could not read the terraform version: %v", err)
+	}
+	t.TerraformVersion = version
+	return version, nil
+}
+
+// execute runs a command like executeResult and returns the combined output
+// of the last run
+func (t *Terralib) execute(command string, options []string, env []string) ([]byte, *Match, []Attempt) {
+	result, match, attempts := t.executeResult(command, options, env)
+	return result.Output, match, attempts
+}
+
+// executeResult runs a command until it succeeds or fails with an error that
+// is not retried, either because the state is locked and LockWait has not
+// passed or because the retry policy of the command allows it. It returns the
+// result and error of the last run along with every attempt
+func (t *Terralib) executeResult(command string, options []string, env []string) (Result, *Match, []Attempt) {
+	var attempts []Attempt
+	policy := t.retryPolicy(command, options)
+	lockDeadline := time.Now().Add(t.LockWait)
+	retries := 0
+	for {
+		t.log(LogDebug, "Running terraform "+command, map[string]interface{}{
+			"command": command,
+			"args":    redactOptions(options),
+			"dir":     t.ConfigPath,
+		})
+		start := time.Now()
+		result, err := t.run(command, options, env)
+		output := result.Output
+		match := t.classify(command, output)
+		if promptErr, ok := err.(promptError); ok {
+			match = &Match{Code: ErrInteractivePrompt, Reason: promptErr.prompt.Text}
+		} else if err != nil {
+			match = &Match{Code: ErrCommandNotRun, Reason: err.Error()}
+		}
+		attempt := Attempt{
+			Raw:      string(output),
+			Duration: time.Since(start),
+		}
+		if match != nil {
+			attempt.Code = match.Code
+		}
+		attempts = append(attempts, attempt)
+		fields := map[string]interface{}{
+			"command":    command,
+			"args":       redactOptions(options),
+			"dir":        t.ConfigPath,
+			"exit_code":  result.ExitCode,
+			"duration":   attempt.Duration,
+			"error_code": attempt.Code,
+		}
+		if t.TerraformVersion != "" {
+			fields["terraform_version"] = t.TerraformVersion
+		}
+		if match == nil {
+			t.log(LogInfo, "Ran terraform "+command, fields)
+			return result, match, attempts
+		}
+		if match.Code == ErrStateLocked {
+			wait := time.Until(lockDeadline)
+			if wait <= 0 {
+				t.log(LogError, "Ran terraform "+command, fields)
+				return result, match, attempts
+			}
+			if interval := t.lockPollInterval(); interval < wait {
+				wait = interval
+			}
+			t.log(LogWarn, "State locked, waiting to run terraform "+command+" again", fields)
+			if err := sleep(t.context(), wait); err != nil {
+				t.log(LogError, "Stopped waiting to run terraform "+command, fields)
+				return result, match, attempts
+			}
+			continue
+		}
+		retries++
+		if !policy.retryable(match.Code, retries) {
+			t.log(LogError, "Ran terraform "+command, fields)
+			return result, match, attempts
+		}
+		t.log(LogWarn, "Retrying terraform "+command, fields)
+		event := RetryEvent{
+			Command: command,
+			Attempt: retries,
+			Code:    match.Code,
+			Reason:  match.Reason,
+			Delay:   policy.delay(retries),
+		}
+		if policy.OnRetry != nil {
+			policy.OnRetry(event)
+		}
+		t.retryHooks(event)
+		if err := sleep(t.context(), event.Delay); err != nil {
+			t.log(LogError, "Stopped retrying terraform "+command, fields)
+			return result, match, attempts
+		}
+	}
+}
@@ -0,0 +1,127 @@
+package terralib
+
+import (
+	"bytes"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+var (
+	diagnosticWithRegexp     = regexp.MustCompile(`(?m)^\s+with ([^,\s]+),`)
+	diagnosticResourceRegexp = regexp.MustCompile(`in (resource|data|module) "([^"]+)"(?: "([^"]+)")?:`)
+	diagnosticSourceRegexp   = regexp.MustCompile(`(?m)^\s*\d+: (resource|data|module) "([^"]+)"(?: "([^"]+)")?`)
+	diagnosticToWorkRegexp   = regexp.MustCompile(`To work with (\S+) its original provider`)
+	diagnosticOnRegexp       = regexp.MustCompile(`(?m)^\s*on (\S+) line (\d+)`)
+	diagnosticFileRegexp     = regexp.MustCompile(`in (?:the )?file "([^"]+)"`)
+	diagnosticVariableRegexp = regexp.MustCompile(`(?:variable (?:named )?"([^"]+)"|var\.(\w+)|TF_VAR_(\w+))`)
+)
+
+// diagnosticLines is a pattern matching the lines of a diagnostic, as few as
+// possible, without reaching into the next one
+var diagnosticLines = `(?:` + lineNotStartingWith("Error: ") + `)*?`
+
+// lineNotStartingWith returns a pattern matching a whole line, with its
+// newline, that does not start with prefix
+func lineNotStartingWith(prefix string) string {
+	pattern := `\n`
+	for i := len(prefix) - 1; i >= 0; i-- {
+		c := regexp.QuoteMeta(prefix[i : i+1])
+		pattern = `\n|[^` + c + `\n][^\n]*\n|` + c + `(?:` + pattern + `)`
+	}
+	return pattern
+}
+
+// unframeDiagnostics removes the frame drawn by terraform 0.15+ around
+// diagnostics, so they read as in earlier versions
+func unframeDiagnostics(output []byte) []byte {
+	if !bytes.Contains(output, []byte("│")) {
+		return output
+	}
+	lines := bytes.Split(output, []byte("\n"))
+	unframed := lines[:0]
+	for _, line := range lines {
+		trimmed := bytes.TrimSpace(line)
+		if bytes.Equal(trimmed, []byte("╷")) || bytes.Equal(trimmed, []byte("╵")) {
+			continue
+		}
+		if bytes.HasPrefix(line, []byte("│")) {
+			line = bytes.TrimPrefix(bytes.TrimPrefix(line, []byte("│")), []byte(" "))
+		}
+		unframed = append(unframed, line)
+	}
+	return bytes.Join(unframed, []byte("\n"))
+}
+
+// diagnosticBlock returns the part of the output holding the diagnostic
+// that contains reason, up to the next error
+func diagnosticBlock(output []byte, reason string) []byte {
+	start := bytes.Index(output, []byte(reason))
+	if start < 0 {
+		return nil
+	}
+	block := output[start:]
+	if end := bytes.Index(block[1:], []byte("\nError: ")); end >= 0 {
+		block = block[:end+1]
+	}
+	return block
+}
+
+// diagnosticAddress returns the resource or module address a diagnostic refers
+// to, as printed by terraform 0.15+ ("with aws_instance.web,") or else derived
+// from the source context ("in resource "aws_instance" "web":") or the block
+// it points at ("12: module "vpc" {")
+func diagnosticAddress(block []byte) string {
+	if m := diagnosticWithRegexp.FindSubmatch(block); m != nil {
+		return string(m[1])
+	}
+	if m := diagnosticToWorkRegexp.FindSubmatch(block); m != nil {
+		return string(m[1])
+	}
+	if m := diagnosticResourceRegexp.FindSubmatch(block); m != nil {
+		return blockAddress(string(m[1]), string(m[2]), string(m[3]))
+	}
+	if m := diagnosticSourceRegexp.FindSubmatch(block); m != nil {
+		return blockAddress(string(m[1]), string(m[2]), string(m[3]))
+	}
+	return ""
+}
+
+// blockAddress returns the address of a resource, data or module block
+func blockAddress(kind, typeOrName, name string) string {
+	switch kind {
+	case "module":
+		return "module." + typeOrName
+	case "data":
+		return "data." + typeOrName + "." + name
+	}
+	return typeOrName + "." + name
+}
+
+// diagnosticLocation returns the file and line a diagnostic refers to, from its
+// source context ("on main.tf line 12") or else the file it names
+func diagnosticLocation(block []byte) (string, int) {
+	if m := diagnosticOnRegexp.FindSubmatch(block); m != nil {
+		line, _ := strconv.Atoi(string(m[2]))
+		return strings.TrimSuffix(string(m[1]), ","), line
+	}
+	if m := diagnosticFileRegexp.FindSubmatch(block); m != nil {
+		return string(m[1]), 0
+	}
+	return "", 0
+}
+
+// diagnosticVariable returns the name of the input variable a diagnostic
+// refers to, as in `variable "region"`, `var.region` or `TF_VAR_region`
+func diagnosticVariable(block []byte) string {
+	m := diagnosticVariableRegexp.FindSubmatch(block)
+	if m == nil {
+		return ""
+	}
+	for _, name := range m[1:] {
+		if len(name) > 0 {
+			return string(name)
+		}
+	}
+	return ""
+}
@@ -0,0 +1,198 @@
+package terralib
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// AttributeChange represents an attribute changed by a resource change. The
+// values of sensitive attributes are not set, nor the after value of the
+// attributes known after apply
+type AttributeChange struct {
+	Path              []interface{}
+	Before            interface{}
+	After             interface{}
+	Unknown           bool
+	Sensitive         bool
+	ForcesReplacement bool
+}
+
+// PathString returns the path of the attribute like "ingress[0].cidr_blocks"
+func (c AttributeChange) PathString() string {
+	return formatPath(c.Path)
+}
+
+// Diff compares the before and after values of a change and returns the
+// attributes that change, sorted by path. Nested objects and lists are
+// compared element by element
+func Diff(change Change) []AttributeChange {
+	d := differ{replacePaths: change.ReplacePaths}
+	d.diff(nil, change.Before, change.After, change.AfterUnknown, change.BeforeSensitive, change.AfterSensitive)
+	sort.SliceStable(d.changes, func(i, j int) bool {
+		return pathLess(d.changes[i].Path, d.changes[j].Path)
+	})
+	return d.changes
+}
+
+// pathLess compares paths step by step, so "tags[2]" sorts before "tags[10]".
+// Indices sort before keys, indices numerically and keys lexically, and a
+// path sorts before the paths nested in it
+func pathLess(a, b []interface{}) bool {
+	for i := 0; i < len(a) && i < len(b); i++ {
+		ai, aIsIndex := a[i].(int)
+		bi, bIsIndex := b[i].(int)
+		switch {
+		case aIsIndex && bIsIndex:
+			if ai != bi {
+				return ai < bi
+			}
+		case aIsIndex != bIsIndex:
+			return aIsIndex
+		default:
+			if as, bs := fmt.Sprint(a[i]), fmt.Sprint(b[i]); as != bs {
+				return as < bs
+			}
+		}
+	}
+	return len(a) < len(b)
+}
+
+type differ struct {
+	replacePaths [][]interface{}
+	changes      []AttributeChange
+}
+
+func (d *differ) diff(path []interface{}, before, after, unknown, beforeSensitive, afterSensitive interface{}) {
+	if unknown != true && beforeSensitive != true && afterSensitive != true {
+		beforeMap, beforeIsMap := before.(map[string]interface{})
+		afterMap, afterIsMap := after.(map[string]interface{})
+		if (beforeIsMap || before == nil) && (afterIsMap || after == nil) && (beforeIsMap || afterIsMap) {
+			keys := map[string]bool{}
+			for _, m := range []map[string]interface{}{beforeMap, afterMap, asMap(unknown)} {
+				for k := range m {
+					keys[k] = true
+				}
+			}
+			for k := range keys {
+				d.diff(appendPath(path, k), beforeMap[k], afterMap[k], asMap(unknown)[k],
+					asMap(beforeSensitive)[k], asMap(afterSensitive)[k])
+			}
+			return
+		}
+		beforeList, beforeIsList := before.([]interface{})
+		afterList, afterIsList := after.([]interface{})
+		if (beforeIsList || before == nil) && (afterIsList || after == nil) && (beforeIsList || afterIsList) {
+			n := len(beforeList)
+			if len(afterList) > n {
+				n = len(afterList)
+			}
+			if unknownList := asList(unknown); len(unknownList) > n {
+				n = len(unknownList)
+			}
+			for i := 0; i < n; i++ {
+				d.diff(appendPath(path, i), listAt(beforeList, i), listAt(afterList, i), listAt(asList(unknown), i),
+					listAt(asList(beforeSensitive), i), listAt(asList(afterSensitive), i))
+			}
+			return
+		}
+	}
+	if unknown != true && reflect.DeepEqual(before, after) {
+		return
+	}
+	sensitive := containsTrue(beforeSensitive) || containsTrue(afterSensitive)
+	change := AttributeChange{
+		Path:              path,
+		Unknown:           unknown == true,
+		Sensitive:         sensitive,
+		ForcesReplacement: d.forcesReplacement(path),
+	}
+	if !sensitive {
+		change.Before = before
+		if !change.Unknown {
+			change.After = after
+		}
+	}
+	d.changes = append(d.changes, change)
+}
+
+// forcesReplacement returns whether the path, or the attribute that contains
+// it, is in the replace paths of the change
+func (d *differ) forcesReplacement(path []interface{}) bool {
+	for _, p := range d.replacePaths {
+		if len(p) > len(path) {
+			continue
+		}
+		match := true
+		for i := range p {
+			if fmt.Sprint(p[i]) != fmt.Sprint(path[i]) {
+				match = false
+				break
+			}
+		}
+		if match {
+			return true
+		}
+	}
+	return false
+}
+
+// appendPath returns a new path, so paths of sibling attributes do not share
+// their backing array
+func appendPath(path []interface{}, step interface{}) []interface{} {
+	return append(append([]interface{}(nil), path...), step)
+}
+
+func formatPath(path []interface{}) string {
+	var b strings.Builder
+	for _, step := range path {
+		switch s := step.(type) {
+		case string:
+			if b.Len() > 0 {
+				b.WriteString(".")
+			}
+			b.WriteString(s)
+		default:
+			fmt.Fprintf(&b, "[%v]", s)
+		}
+	}
+	return b.String()
+}
+
+func containsTrue(v interface{}) bool {
+	switch value := v.(type) {
+	case bool:
+		return value
+	case map[string]interface{}:
+		for _, nested := range value {
+			if containsTrue(nested) {
+				return true
+			}
+		}
+	case []interface{}:
+		for _, nested := range value {
+			if containsTrue(nested) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func asMap(v interface{}) map[string]interface{} {
+	m, _ := v.(map[string]interface{})
+	return m
+}
+
+func asList(v interface{}) []interface{} {
+	l, _ := v.([]interface{})
+	return l
+}
+
+func listAt(l []interface{}, i int) interface{} {
+	if i < len(l) {
+		return l[i]
+	}
+	return nil
+}
@@ -0,0 +1,130 @@
+package terralib
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+const changeReplaceTest string = `{
+  "actions": ["delete", "create"],
+  "before": {
+    "id": "i-0c3d",
+    "ami": "ami-1111",
+    "instance_type": "t3.micro",
+    "user_data": "secret-1",
+    "tags": {"Name": "app", "env": "dev"},
+    "ebs_block_device": [{"device_name": "/dev/sdb", "volume_size": 50}]
+  },
+  "after": {
+    "ami": "ami-2222",
+    "instance_type": "t3.micro",
+    "user_data": "secret-2",
+    "tags": {"Name": "app", "env": "prod", "owner": "platform"},
+    "ebs_block_device": [{"device_name": "/dev/sdb", "volume_size": 100}]
+  },
+  "after_unknown": {"id": true, "tags": {}, "ebs_block_device": [{}]},
+  "before_sensitive": {"user_data": true},
+  "after_sensitive": {"user_data": true},
+  "replace_paths": [["ami"], ["ebs_block_device"]]
+}`
+
+func TestDiff(t *testing.T) {
+	var change Change
+	if err := json.Unmarshal([]byte(changeReplaceTest), &change); err != nil {
+		t.Fatal(err)
+	}
+	expected := []AttributeChange{
+		{
+			Path:              []interface{}{"ami"},
+			Before:            "ami-1111",
+			After:             "ami-2222",
+			ForcesReplacement: true,
+		},
+		{
+			Path:              []interface{}{"ebs_block_device", 0, "volume_size"},
+			Before:            float64(50),
+			After:             float64(100),
+			ForcesReplacement: true,
+		},
+		{
+			Path:    []interface{}{"id"},
+			Before:  "i-0c3d",
+			Unknown: true,
+		},
+		{
+			Path:   []interface{}{"tags", "env"},
+			Before: "dev",
+			After:  "prod",
+		},
+		{
+			Path:  []interface{}{"tags", "owner"},
+			After: "platform",
+		},
+		{
+			Path:      []interface{}{"user_data"},
+			Sensitive: true,
+		},
+	}
+	got := Diff(change)
+	if !cmp.Equal(got, expected) {
+		t.Errorf("Got: %+v, Expected: %+v", got, expected)
+	}
+}
+
+func TestAttributeChangePathString(t *testing.T) {
+	c := AttributeChange{Path: []interface{}{"ingress", 0, "cidr_blocks", 1}}
+	expected := "ingress[0].cidr_blocks[1]"
+	if got := c.PathString(); got != expected {
+		t.Errorf("Got: %s, Expected: %s", got, expected)
+	}
+}
+
+func TestDiffSortsIndicesNumerically(t *testing.T) {
+	var change Change
+	content := `{
+  "actions": ["update"],
+  "before": {"names": ["a", "b", "c", "d", "e", "f", "g", "h", "i", "j", "k"], "name": "x"},
+  "after": {"names": ["a", "b", "C", "d", "e", "f", "g", "h", "i", "j", "K"], "name": "y"},
+  "after_unknown": {}
+}`
+	if err := json.Unmarshal([]byte(content), &change); err != nil {
+		t.Fatal(err)
+	}
+	expected := []string{"name", "names[2]", "names[10]"}
+	var got []string
+	for _, c := range Diff(change) {
+		got = append(got, c.PathString())
+	}
+	if !cmp.Equal(got, expected) {
+		t.Errorf("Got: %+v, Expected: %+v", got, expected)
+	}
+}
+
+func TestDiffForcesReplacementParent(t *testing.T) {
+	var change Change
+	content := `{
+  "actions": ["delete", "create"],
+  "before": {"root_block_device": [{"volume_size": 8, "encrypted": false}], "tags": {"Name": "a"}},
+  "after": {"root_block_device": [{"volume_size": 8, "encrypted": true}], "tags": {"Name": "b"}},
+  "after_unknown": {},
+  "replace_paths": [["root_block_device", 0, "encrypted"]]
+}`
+	if err := json.Unmarshal([]byte(content), &change); err != nil {
+		t.Fatal(err)
+	}
+	expected := map[string]bool{
+		"root_block_device[0].encrypted": true,
+		"tags.Name":                      false,
+	}
+	for _, c := range Diff(change) {
+		if c.ForcesReplacement != expected[c.PathString()] {
+			t.Errorf("%s: Got forces replacement: %v", c.PathString(), c.ForcesReplacement)
+		}
+	}
+	d := differ{replacePaths: change.ReplacePaths}
+	if d.forcesReplacement([]interface{}{"root_block_device"}) || d.forcesReplacement(nil) {
+		t.Errorf("Expected the parents of a replace path not to force replacement")
+	}
+}
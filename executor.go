@@ -0,0 +1,122 @@
+package terralib
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"os"
+	"os/exec"
+	"sync"
+	"time"
+)
+
+// Exported error codes
+const (
+	ErrCommandNotRun string = "errCommandNotRun"
+)
+
+// Invocation represents a terraform command to run
+type Invocation struct {
+	// Args are the command and its options, without the terraform binary
+	Args []string
+	// Dir is the directory the command runs in
+	Dir string
+	// Env are environment variables, like "TF_LOG=debug", added to the ones
+	// of the current process
+	Env []string
+	// Stdin is the input of the command. It is read until the command exits
+	Stdin io.Reader
+	// Stdout receives the combined output of the command as it is written
+	Stdout io.Writer
+}
+
+// Result represents the result of an invocation
+type Result struct {
+	// Output is the combined standard output and error of the command
+	Output   []byte
+	Stdout   []byte
+	Stderr   []byte
+	ExitCode int
+	Duration time.Duration
+}
+
+// Executor runs terraform commands. It returns an error only when the command
+// could not be run, which fails the command with ErrCommandNotRun. A command
+// that fails sets the exit code of the result
+type Executor interface {
+	Execute(ctx context.Context, inv Invocation) (Result, error)
+}
+
+// ShellExecutor runs commands with the terraform binary found in the PATH,
+// through the shell so options may hold several quoted arguments
+type ShellExecutor struct{}
+
+// Execute runs the invocation with 'sh -c'. The shell is replaced by terraform,
+// so cancelling the context stops terraform
+func (ShellExecutor) Execute(ctx context.Context, inv Invocation) (Result, error) {
+	var options []string
+	if len(inv.Args) > 1 {
+		options = inv.Args[1:]
+	}
+	cmd := exec.CommandContext(ctx, "sh", "-c", "exec "+formatCommand(inv.Args[0], options))
+	cmd.Dir = inv.Dir
+	if len(inv.Env) > 0 {
+		cmd.Env = append(os.Environ(), inv.Env...)
+	}
+	var stdout, stderr bytes.Buffer
+	combined := &lockedBuffer{w: inv.Stdout}
+	cmd.Stdout = io.MultiWriter(&stdout, combined)
+	cmd.Stderr = io.MultiWriter(&stderr, combined)
+	if inv.Stdin != nil {
+		// Copied apart from the command, so it does not wait for the end of
+		// a reader that is only closed once the command exits
+		stdin, err := cmd.StdinPipe()
+		if err != nil {
+			return Result{ExitCode: -1}, err
+		}
+		go func() {
+			io.Copy(stdin, inv.Stdin)
+			stdin.Close()
+		}()
+	}
+	start := time.Now()
+	err := cmd.Run()
+	result := Result{
+		Output:   combined.Bytes(),
+		Stdout:   stdout.Bytes(),
+		Stderr:   stderr.Bytes(),
+		Duration: time.Since(start),
+	}
+	if exitErr, ok := err.(*exec.ExitError); ok {
+		result.ExitCode = exitErr.ExitCode()
+		return result, nil
+	}
+	if err != nil {
+		result.ExitCode = -1
+		return result, err
+	}
+	return result, nil
+}
+
+// lockedBuffer keeps the order of the writes of standard output and error,
+// passing them to w when set
+type lockedBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+	w   io.Writer
+}
+
+func (b *lockedBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.w != nil {
+		b.w.Write(p)
+	}
+	return b.buf.Write(p)
+}
+
+func (b *lockedBuffer) Bytes() []byte {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Bytes()
+}
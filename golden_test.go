@@ -0,0 +1,176 @@
+package terralib
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+var update = flag.Bool("update", false, "update the expected results of testdata/golden")
+
+// goldenResult represents what the parsers read from a fixture
+type goldenResult struct {
+	Providers []goldenProvider  `json:"providers,omitempty"`
+	Modules   []InstalledModule `json:"modules,omitempty"`
+	Backend   string            `json:"backend,omitempty"`
+	Plan      *ShowOutput       `json:"plan,omitempty"`
+	State     *State            `json:"state,omitempty"`
+	Validate  *ValidateOutput   `json:"validate,omitempty"`
+	Graph     *GraphOutput      `json:"graph,omitempty"`
+	Error     *goldenError      `json:"error,omitempty"`
+}
+
+// goldenProvider is a Provider leaving out the fields that are not set, so
+// the fixtures of versions that do not print them keep their expected result
+type goldenProvider struct {
+	Name      string
+	Path      string
+	Version   string
+	Source    string   `json:",omitempty"`
+	Locked    bool     `json:",omitempty"`
+	Reused    bool     `json:",omitempty"`
+	Signature string   `json:",omitempty"`
+	Signer    string   `json:",omitempty"`
+	KeyID     string   `json:",omitempty"`
+	Hashes    []string `json:",omitempty"`
+}
+
+// goldenError holds the fields of the error types of terralib parsed from
+// the output. Those an error type does not have, or that are not set, are
+// left out of the expected results
+type goldenError struct {
+	Code     string       `json:"code"`
+	Reason   string       `json:"reason,omitempty"`
+	Address  string       `json:"address,omitempty"`
+	Provider string       `json:"provider,omitempty"`
+	LockID   string       `json:"lockID,omitempty"`
+	Lock     *LockInfo    `json:"lock,omitempty"`
+	Variable string       `json:"variable,omitempty"`
+	File     string       `json:"file,omitempty"`
+	Line     int          `json:"line,omitempty"`
+	Cycle    *goldenCycle `json:"cycle,omitempty"`
+}
+
+// goldenCycle holds the nodes of a cycle and the addresses parsed from them
+type goldenCycle struct {
+	Nodes     []string `json:"nodes"`
+	Addresses []string `json:"addresses,omitempty"`
+}
+
+// goldenErrorOf returns the fields of one of the error types of terralib,
+// nil when err is nil. They are copied by name through JSON, which matches
+// them regardless of case
+func goldenErrorOf(err error) *goldenError {
+	if err == nil {
+		return nil
+	}
+	var fields struct {
+		goldenError
+		Cycle *CycleError
+	}
+	data, marshalErr := json.Marshal(err)
+	if marshalErr == nil {
+		marshalErr = json.Unmarshal(data, &fields)
+	}
+	golden := fields.goldenError
+	if marshalErr != nil {
+		golden.Code = err.Error()
+	}
+	if fields.Cycle != nil {
+		golden.Cycle = &goldenCycle{Nodes: fields.Cycle.Nodes}
+		for _, address := range fields.Cycle.Addresses {
+			golden.Cycle.Addresses = append(golden.Cycle.Addresses, address.String())
+		}
+	}
+	return &golden
+}
+
+// parseFixture runs the parsers of a command on the output of a terraform
+// version. Fixtures are named after the command they are the output of
+func parseFixture(version string, name string, output []byte) goldenResult {
+	command := strings.SplitN(name, "_", 2)[0]
+	match := DefaultClassifiers.Classify(command, version, output)
+	var result goldenResult
+	var err error
+	switch command {
+	case CommandInit:
+		for _, provider := range getProvidersFromOutput(output) {
+			result.Providers = append(result.Providers, goldenProvider(provider))
+		}
+		result.Modules = getModulesFromOutput(output)
+		result.Backend = getBackendFromOutput(output)
+		err = initErrorFrom(match)
+	case CommandPlan:
+		err = planErrorFrom(match, output)
+	case CommandApply:
+		err = applyErrorFrom(match, output)
+	case CommandShow:
+		if strings.HasPrefix(name, "show_state") {
+			result.State = &State{}
+			json.Unmarshal(output, result.State)
+		} else {
+			result.Plan = &ShowOutput{}
+			json.Unmarshal(output, result.Plan)
+		}
+		err = showErrorFrom(match)
+	case CommandValidate:
+		validateOutput, parseErr := parseValidateOutput(output)
+		result.Validate = &validateOutput
+		err = parseErr
+		if err == nil {
+			err = validateErrorFrom(match)
+		}
+	case CommandGraph:
+		graphOutput := ParseGraph(output)
+		graphOutput.Raw = ""
+		result.Graph = &graphOutput
+	}
+	result.Error = goldenErrorOf(err)
+	return result
+}
+
+// TestGolden checks the parsers against the outputs of several terraform
+// versions in testdata/golden/<version>/<command>_<case>.txt. The expected
+// result of each is the .json file next to it, written with -update
+func TestGolden(t *testing.T) {
+	fixtures, err := filepath.Glob(filepath.Join("testdata", "golden", "*", "*.txt"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(fixtures) == 0 {
+		t.Fatal("No fixtures found in testdata/golden")
+	}
+	for _, fixture := range fixtures {
+		version := filepath.Base(filepath.Dir(fixture))
+		name := strings.TrimSuffix(filepath.Base(fixture), ".txt")
+		t.Run(version+"/"+name, func(t *testing.T) {
+			output, err := ioutil.ReadFile(fixture)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, err := json.MarshalIndent(parseFixture(version, name, output), "", "  ")
+			if err != nil {
+				t.Fatal(err)
+			}
+			got = append(got, '\n')
+			golden := strings.TrimSuffix(fixture, ".txt") + ".json"
+			if *update {
+				if err := ioutil.WriteFile(golden, got, 0644); err != nil {
+					t.Fatal(err)
+				}
+				return
+			}
+			expected, err := ioutil.ReadFile(golden)
+			if err != nil {
+				t.Fatalf("%v, run 'go test -run TestGolden -update' to create it", err)
+			}
+			if !bytes.Equal(got, expected) {
+				t.Errorf("Got:\n%s\nExpected:\n%s", got, expected)
+			}
+		})
+	}
+}
@@ -0,0 +1,208 @@
+package terralib
+
+import (
+	"regexp"
+	"strings"
+)
+
+// Exported error codes
+const (
+	ErrGraphDefault string = "errGraphDefault"
+)
+
+var graphClassifiers = []Classifier{
+	defaultClassifier(ErrGraphDefault, CommandGraph),
+}
+
+var (
+	graphEdgeRegexp  = regexp.MustCompile(`^\s*"((?:[^"\\]|\\.)*)"\s*->\s*"((?:[^"\\]|\\.)*)"(.*)$`)
+	graphNodeRegexp  = regexp.MustCompile(`^\s*"((?:[^"\\]|\\.)*)"\s*\[`)
+	graphCycleRegexp = regexp.MustCompile(`color\s*=\s*"red"`)
+	// nodeSuffixRegexp matches the suffix terraform adds to the name of some
+	// nodes, like " (expand)", " (destroy)", " (close)" or " (EachMode fixup)"
+	nodeSuffixRegexp = regexp.MustCompile(` \([^()]+\)$`)
+)
+
+const (
+	graphNodePrefix  = "[root] "
+	graphExpandLabel = " (expand)"
+)
+
+// referencePrefixes are the objects other than resources that can be part of
+// a cycle, like var.region or local.name
+var referencePrefixes = map[string]bool{
+	"var":       true,
+	"local":     true,
+	"output":    true,
+	"path":      true,
+	"terraform": true,
+	"count":     true,
+	"each":      true,
+	"self":      true,
+}
+
+// GraphEdge represents a dependency between two nodes of the graph, From
+// depends on To. Cycle is set for the edges drawn as part of a cycle with
+// -draw-cycles
+type GraphEdge struct {
+	From  string
+	To    string
+	Cycle bool
+}
+
+// GraphOutput represents the output of the graph command. Node names are
+// printed without the "[root] " prefix and " (expand)" suffix of terraform
+// 0.13+, so they read like addresses
+type GraphOutput struct {
+	Raw      string
+	Nodes    []string
+	Edges    []GraphEdge
+	Attempts []Attempt
+}
+
+// GraphError represents an error on the Graph command
+type GraphError struct {
+	Reason string
+	Code   string
+}
+
+func (e GraphError) Error() string {
+	return e.Code
+}
+
+// CycleError represents a dependency cycle reported by terraform. Nodes are
+// the names listed by terraform and Addresses the resources among them. Edges
+// are set by GraphOutput.Explain
+type CycleError struct {
+	Reason    string
+	Code      string
+	Nodes     []string
+	Addresses []ResourceAddress
+	Edges     []GraphEdge
+}
+
+func (e CycleError) Error() string {
+	return e.Code
+}
+
+// Graph executes the 'terraform graph' command and parses the DOT graph it
+// prints
+func (t *Terralib) Graph(options []string) (graphOutput GraphOutput, err error) {
+	if err := t.beforeCommand(CommandGraph, options); err != nil {
+		return GraphOutput{}, err
+	}
+	defer func() {
+		t.afterCommand(CommandGraph, options, graphOutput, err)
+	}()
+	stdOutputError, match, attempts := t.execute(CommandGraph, options, nil)
+	if match != nil {
+		return GraphOutput{Raw: string(stdOutputError), Attempts: attempts}, GraphError{
+			Reason: match.Reason,
+			Code:   match.Code,
+		}
+	}
+	graphOutput = ParseGraph(stdOutputError)
+	graphOutput.Attempts = attempts
+	return graphOutput, nil
+}
+
+// ParseGraph reads the nodes and edges of a DOT graph printed by terraform
+func ParseGraph(output []byte) GraphOutput {
+	graphOutput := GraphOutput{Raw: string(output)}
+	seen := map[string]bool{}
+	addNode := func(name string) {
+		if !seen[name] {
+			seen[name] = true
+			graphOutput.Nodes = append(graphOutput.Nodes, name)
+		}
+	}
+	// -draw-cycles draws the edges of cycles again, in red
+	edges := map[GraphEdge]int{}
+	for _, line := range strings.Split(string(output), "\n") {
+		if m := graphEdgeRegexp.FindStringSubmatch(line); m != nil {
+			edge := GraphEdge{
+				From: graphNodeName(m[1]),
+				To:   graphNodeName(m[2]),
+			}
+			cycle := graphCycleRegexp.MatchString(m[3])
+			if i, ok := edges[edge]; ok {
+				graphOutput.Edges[i].Cycle = graphOutput.Edges[i].Cycle || cycle
+				continue
+			}
+			addNode(edge.From)
+			addNode(edge.To)
+			edges[edge] = len(graphOutput.Edges)
+			edge.Cycle = cycle
+			graphOutput.Edges = append(graphOutput.Edges, edge)
+			continue
+		}
+		if m := graphNodeRegexp.FindStringSubmatch(line); m != nil {
+			addNode(graphNodeName(m[1]))
+		}
+	}
+	return graphOutput
+}
+
+// Explain sets the edges of the graph between the nodes of the cycle, the
+// dependencies to break to fix it, and returns them. Nodes are compared
+// without their suffixes, as a cycle of destroy nodes like
+// "aws_instance.a (destroy)" may be explained with a graph of another type
+func (g GraphOutput) Explain(cycle *CycleError) []GraphEdge {
+	nodes := map[string]bool{}
+	for _, node := range cycle.Nodes {
+		nodes[graphNodeObject(node)] = true
+	}
+	cycle.Edges = nil
+	for _, edge := range g.Edges {
+		from, to := graphNodeObject(edge.From), graphNodeObject(edge.To)
+		if from != to && nodes[from] && nodes[to] {
+			cycle.Edges = append(cycle.Edges, edge)
+		}
+	}
+	return cycle.Edges
+}
+
+// graphNodeObject returns the object of a node, its name without suffix
+func graphNodeObject(node string) string {
+	return nodeSuffixRegexp.ReplaceAllString(node, "")
+}
+
+// graphNodeName unquotes a node name of the DOT graph and removes the
+// decorations added by terraform
+func graphNodeName(name string) string {
+	name = strings.NewReplacer(`\"`, `"`, `\\`, `\`).Replace(name)
+	name = strings.TrimPrefix(name, graphNodePrefix)
+	return strings.TrimSuffix(name, graphExpandLabel)
+}
+
+// ParseCycle reads the nodes of a cycle from the reason of an ErrCycle
+// error, "Cycle: aws_instance.a, aws_instance.b"
+func ParseCycle(reason string) *CycleError {
+	cycle := &CycleError{
+		Reason: reason,
+		Code:   ErrCycle,
+	}
+	list := strings.TrimSpace(strings.TrimPrefix(reason, "Cycle:"))
+	if list == "" {
+		return cycle
+	}
+	for _, node := range strings.Split(list, ", ") {
+		node = strings.TrimSpace(node)
+		cycle.Nodes = append(cycle.Nodes, node)
+		// Resources are listed as "aws_instance.a (destroy)" for their
+		// other nodes than the default one
+		address, err := ParseResourceAddress(graphNodeObject(node))
+		if err == nil && !referencePrefixes[address.Type] {
+			cycle.Addresses = append(cycle.Addresses, address)
+		}
+	}
+	return cycle
+}
+
+// cycleFrom returns the cycle of an ErrCycle match, nil for other codes
+func cycleFrom(match *Match) *CycleError {
+	if match.Code != ErrCycle {
+		return nil
+	}
+	return ParseCycle(match.Reason)
+}
@@ -0,0 +1,111 @@
+package terralib
+
+import (
+	"context"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+const graphOutputCycleTest string = `digraph {
+	compound = "true"
+	newrank = "true"
+	subgraph "root" {
+		"[root] aws_security_group.app (expand)" [label = "aws_security_group.app", shape = "box"]
+		"[root] aws_security_group.db (expand)" [label = "aws_security_group.db", shape = "box"]
+		"[root] module.net.aws_subnet.private (expand)" [label = "module.net.aws_subnet.private", shape = "box"]
+		"[root] aws_security_group.app (expand)" -> "[root] module.net.aws_subnet.private (expand)"
+		"[root] aws_security_group.app (expand)" -> "[root] aws_security_group.db (expand)"
+		"[root] aws_security_group.db (expand)" -> "[root] local.ports (expand)"
+		"[root] local.ports (expand)" -> "[root] aws_security_group.app (expand)"
+		"[root] local.ports (expand)" -> "[root] aws_security_group.app (expand)" [color = "red", penwidth = "2.0"]
+	}
+}
+`
+
+func TestParseCycle(t *testing.T) {
+	output := "\nError: Cycle: local.ports (expand), aws_security_group.db, aws_security_group.app (destroy)\n"
+	got := findPlanError([]byte(output)).(PlanError).Cycle
+	expected := &CycleError{
+		Reason: "Cycle: local.ports (expand), aws_security_group.db, aws_security_group.app (destroy)",
+		Code:   ErrCycle,
+		Nodes:  []string{"local.ports (expand)", "aws_security_group.db", "aws_security_group.app (destroy)"},
+		Addresses: []ResourceAddress{
+			{Mode: ModeManaged, Type: "aws_security_group", Name: "db"},
+			{Mode: ModeManaged, Type: "aws_security_group", Name: "app"},
+		},
+	}
+	if !cmp.Equal(got, expected) {
+		t.Errorf("Got: %+v, Expected: %+v", got, expected)
+	}
+}
+
+func TestGraphExplainCycle(t *testing.T) {
+	tf := Terralib{
+		Executor: executorFunc(func(ctx context.Context, inv Invocation) (Result, error) {
+			return Result{Output: []byte(graphOutputCycleTest)}, nil
+		}),
+	}
+	graph, err := tf.Graph([]string{"-draw-cycles"})
+	if err != nil {
+		t.Fatalf("Got error: %v", err)
+	}
+	expectedNodes := []string{
+		"aws_security_group.app",
+		"aws_security_group.db",
+		"module.net.aws_subnet.private",
+		"local.ports",
+	}
+	if !cmp.Equal(graph.Nodes, expectedNodes) {
+		t.Errorf("Got nodes: %v, Expected: %v", graph.Nodes, expectedNodes)
+	}
+	cycle := ParseCycle("Cycle: aws_security_group.app (expand), aws_security_group.db (expand), local.ports (expand)")
+	expected := []GraphEdge{
+		{From: "aws_security_group.app", To: "aws_security_group.db"},
+		{From: "aws_security_group.db", To: "local.ports"},
+		{From: "local.ports", To: "aws_security_group.app", Cycle: true},
+	}
+	if got := graph.Explain(cycle); !cmp.Equal(got, expected) {
+		t.Errorf("Got: %+v, Expected: %+v", got, expected)
+	}
+	if !cmp.Equal(cycle.Edges, expected) {
+		t.Errorf("Got cycle edges: %+v, Expected: %+v", cycle.Edges, expected)
+	}
+}
+
+const graphOutputDestroyCycleTest string = `digraph {
+	compound = "true"
+	newrank = "true"
+	subgraph "root" {
+		"[root] aws_instance.a (destroy)" [label = "aws_instance.a (destroy)", shape = "box"]
+		"[root] aws_instance.b (destroy)" [label = "aws_instance.b (destroy)", shape = "box"]
+		"[root] aws_instance.a (destroy)" -> "[root] aws_instance.b (destroy)"
+		"[root] aws_instance.b (destroy)" -> "[root] aws_instance.a (destroy)"
+		"[root] aws_instance.b (destroy)" -> "[root] aws_instance.b"
+		"[root] meta.count-boundary (EachMode fixup)" -> "[root] aws_instance.a (destroy)"
+		"[root] provider.aws (close)" -> "[root] aws_instance.a (destroy)"
+		"[root] aws_instance.b (destroy)" -> "[root] aws_instance.a (destroy)" [color = "red", penwidth = "2.0"]
+	}
+}
+`
+
+func TestGraphExplainDestroyCycle(t *testing.T) {
+	graph := ParseGraph([]byte(graphOutputDestroyCycleTest))
+	cycle := ParseCycle("Cycle: aws_instance.a (destroy deposed 5f1e2d3c), aws_instance.b (destroy), provider.aws (close)")
+	expectedAddresses := []ResourceAddress{
+		{Mode: ModeManaged, Type: "aws_instance", Name: "a"},
+		{Mode: ModeManaged, Type: "aws_instance", Name: "b"},
+		{Mode: ModeManaged, Type: "provider", Name: "aws"},
+	}
+	if !cmp.Equal(cycle.Addresses, expectedAddresses) {
+		t.Errorf("Got addresses: %+v, Expected: %+v", cycle.Addresses, expectedAddresses)
+	}
+	expected := []GraphEdge{
+		{From: "aws_instance.a (destroy)", To: "aws_instance.b (destroy)"},
+		{From: "aws_instance.b (destroy)", To: "aws_instance.a (destroy)", Cycle: true},
+		{From: "provider.aws (close)", To: "aws_instance.a (destroy)"},
+	}
+	if got := graph.Explain(cycle); !cmp.Equal(got, expected) {
+		t.Errorf("Got: %+v, Expected: %+v", got, expected)
+	}
+}
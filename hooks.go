@@ -0,0 +1,86 @@
+package terralib
+
+// Exported error codes
+const (
+	ErrCommandVetoed string = "errCommandVetoed"
+)
+
+// Hooks holds functions called around every command. Nil functions are skipped
+type Hooks struct {
+	// OnBeforeCommand is called before running a command. Returning an error
+	// stops the command, which fails with ErrCommandVetoed
+	OnBeforeCommand func(command string, options []string) error
+	// OnAfterCommand is called after running a command with its parsed result,
+	// one of InitOutput, PlanOutput, ApplyOutput, ShowOutput, State,
+	// ValidateOutput, ProvidersLockOutput, GraphOutput, *StateFile,
+	// StatePushOutput or ForceUnlockOutput, and its error
+	OnAfterCommand func(command string, options []string, result interface{}, err error)
+	OnPlanComplete func(output PlanOutput, err error)
+	// OnApplyComplete is called after Apply and Destroy
+	OnApplyComplete func(output ApplyOutput, err error)
+	// OnRetry is called before a command is run again by its retry policy
+	OnRetry func(event RetryEvent)
+}
+
+// HookError represents a command stopped by an OnBeforeCommand hook
+type HookError struct {
+	Reason string
+	Code   string
+	Err    error
+}
+
+func (e HookError) Error() string {
+	return e.Code
+}
+
+// Unwrap returns the error returned by the hook
+func (e HookError) Unwrap() error {
+	return e.Err
+}
+
+func (t *Terralib) beforeCommand(command string, options []string) error {
+	for _, h := range t.Hooks {
+		if h.OnBeforeCommand == nil {
+			continue
+		}
+		if err := h.OnBeforeCommand(command, options); err != nil {
+			t.log(LogWarn, "Hook stopped terraform "+command, map[string]interface{}{
+				"command": command,
+				"args":    redactOptions(options),
+				"reason":  err.Error(),
+			})
+			return HookError{
+				Reason: err.Error(),
+				Code:   ErrCommandVetoed,
+				Err:    err,
+			}
+		}
+	}
+	return nil
+}
+
+func (t *Terralib) afterCommand(command string, options []string, result interface{}, err error) {
+	for _, h := range t.Hooks {
+		if h.OnAfterCommand != nil {
+			h.OnAfterCommand(command, options, result, err)
+		}
+		switch output := result.(type) {
+		case PlanOutput:
+			if h.OnPlanComplete != nil {
+				h.OnPlanComplete(output, err)
+			}
+		case ApplyOutput:
+			if h.OnApplyComplete != nil {
+				h.OnApplyComplete(output, err)
+			}
+		}
+	}
+}
+
+func (t *Terralib) retryHooks(event RetryEvent) {
+	for _, h := range t.Hooks {
+		if h.OnRetry != nil {
+			h.OnRetry(event)
+		}
+	}
+}
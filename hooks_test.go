@@ -0,0 +1,69 @@
+package terralib
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestBeforeCommandVeto(t *testing.T) {
+	var called []string
+	lint := errors.New("Configuration is not formatted")
+	tf := Terralib{
+		Hooks: []Hooks{
+			{
+				OnBeforeCommand: func(command string, options []string) error {
+					called = append(called, "lint "+command)
+					return lint
+				},
+			},
+			{
+				OnBeforeCommand: func(command string, options []string) error {
+					called = append(called, "notify "+command)
+					return nil
+				},
+				OnAfterCommand: func(command string, options []string, result interface{}, err error) {
+					called = append(called, "after "+command)
+				},
+			},
+		},
+	}
+	_, err := tf.Plan([]string{"-no-color"})
+	expected := HookError{
+		Reason: "Configuration is not formatted",
+		Code:   ErrCommandVetoed,
+		Err:    lint,
+	}
+	if !cmp.Equal(err, expected, cmp.Comparer(func(a, b error) bool { return a == b })) {
+		t.Errorf("Got: %+v, Expected: %+v", err, expected)
+	}
+	if !errors.Is(err, lint) {
+		t.Errorf("Expected the error to wrap the error of the hook")
+	}
+	if !cmp.Equal(called, []string{"lint plan"}) {
+		t.Errorf("Got: %v, Expected only the first hook to be called", called)
+	}
+}
+
+func TestAfterCommand(t *testing.T) {
+	var called []string
+	tf := Terralib{
+		Hooks: []Hooks{{
+			OnAfterCommand: func(command string, options []string, result interface{}, err error) {
+				called = append(called, "after "+command+" "+result.(PlanOutput).Raw)
+			},
+			OnPlanComplete: func(output PlanOutput, err error) {
+				called = append(called, "plan "+output.Raw)
+			},
+			OnApplyComplete: func(output ApplyOutput, err error) {
+				called = append(called, "apply "+output.Raw)
+			},
+		}},
+	}
+	tf.afterCommand(CommandPlan, nil, PlanOutput{Raw: "No changes."}, nil)
+	expected := []string{"after plan No changes.", "plan No changes."}
+	if !cmp.Equal(called, expected) {
+		t.Errorf("Got: %v, Expected: %v", called, expected)
+	}
+}
@@ -3,9 +3,11 @@ package terralib
 import (
 	"bufio"
 	"bytes"
-	"errors"
+	"encoding/json"
 	"fmt"
-	"os/exec"
+	"io/ioutil"
+	"os"
+	"path/filepath"
 	"regexp"
 	"strings"
 )
@@ -23,31 +25,84 @@ const (
 	ErrSignatureVerification       string = "errSignatureVerification"
 )
 
-var initErrors = map[string]error{
-	ErrInitCopyNotEmpty:            errors.New("The working directory already contains files"),
-	ErrProviderNotFound:            errors.New("Provider \"(.*)\" not available for installation"),
-	ErrDiscoveryServiceUnreachable: errors.New("Registry service unreachable"),
-	ErrProviderVersionsUnsuitable:  errors.New("No provider \"(.*)\" plugins meet the constraint \"(.*)\""),
-	ErrProviderIncompatible:        errors.New("Provider \"(.*)\" (.*) is not compatible with Terraform (.*)"),
-	ErrProviderInstallError:        errors.New("Error installing provider \"(.*)\": (.*)"),
-	ErrMissingProvidersNoInstall: errors.New("The following provider constraints are not met by the currently-installed\n" +
-		"provider plugins:\n\n" +
-		"(.*)"),
-	ErrChecksumVerification:  errors.New("Error verifying checksum for provider \"(.*)\""),
-	ErrSignatureVerification: errors.New("Error verifying GPG signature for provider \"(.*)\""),
-}
-
-// Provider represents a Terraform provider
+var initClassifiers = []Classifier{
+	NewClassifier(ErrInitCopyNotEmpty, "The working directory already contains files", CommandInit),
+	NewClassifier(ErrProviderNotFound, "Provider \"(.*)\" not available for installation", CommandInit),
+	NewClassifier(ErrDiscoveryServiceUnreachable, "Registry service unreachable", CommandInit),
+	NewClassifier(ErrProviderVersionsUnsuitable, "No provider \"(.*)\" plugins meet the constraint \"(.*)\"", CommandInit),
+	NewClassifier(ErrProviderIncompatible, "Provider \"(.*)\" (.*) is not compatible with Terraform (.*)", CommandInit),
+	NewClassifier(ErrProviderInstallError, "Error installing provider \"(.*)\": (.*)", CommandInit),
+	NewClassifier(ErrMissingProvidersNoInstall, "The following provider constraints are not met by the currently-installed\n"+
+		"provider plugins:\n\n"+
+		"(.*)", CommandInit),
+	NewClassifier(ErrChecksumVerification, "Error verifying checksum for provider \"(.*)\"", CommandInit),
+	NewClassifier(ErrSignatureVerification, "Error verifying GPG signature for provider \"(.*)\"", CommandInit),
+	providerInstallClassifier(ErrProviderNotFound, `does not have a\s+provider named`),
+	providerInstallClassifier(ErrDiscoveryServiceUnreachable, `could not connect to|Failed to request discovery document`),
+	providerInstallClassifier(ErrProviderVersionsUnsuitable, `no available releases match the given constraints`),
+	providerInstallClassifier(ErrChecksumVerification, `doesn't match any of the checksums`),
+	providerInstallClassifier(ErrSignatureVerification, `(?:signature|authentication)\s+(?:verification\s+)?failed`),
+	providerInstallClassifier(ErrProviderInstallError, ``),
+}
+
+// providerInstallClassifier returns a classifier for the provider installation
+// errors of terraform 0.13+, whose cause is in the detail of the diagnostic
+func providerInstallClassifier(code string, detail string) Classifier {
+	pattern := `Error: (?P<reason>Failed to (?:install|query available) provider[^\n]*?)\.?\n`
+	if detail != "" {
+		pattern += `(?s:.*?)(?:` + detail + `)`
+	}
+	c := NewClassifier(code, pattern, CommandInit)
+	c.MinVersion = "0.13.0"
+	return c
+}
+
+// Provider represents a Terraform provider. Path is set by terraform 0.12,
+// Source and the installation details by terraform 0.13+
 type Provider struct {
 	Name    string
 	Path    string
 	Version string
+	// Source is the source address, like "hashicorp/aws"
+	Source string
+	// Locked is set when the version was selected by the dependency lock file
+	Locked bool
+	// Reused is set when a previously installed package was used
+	Reused bool
+	// Signature is how the package was authenticated, like "signed",
+	// "self-signed", "unauthenticated" or "verified checksum"
+	Signature string
+	// Signer and KeyID identify who signed the package
+	Signer string
+	KeyID  string
+	// Hashes are the checksums recorded in the dependency lock file
+	Hashes []string
+}
+
+// InstalledModule represents a module installed or upgraded by init
+type InstalledModule struct {
+	// Name is the key of the module, like "vpc" or "vpc.subnets" when nested
+	Name    string
+	Source  string
+	Version string
+	// Dir is the directory of the module, relative to the configuration
+	Dir string
 }
 
 // InitOutput represents the output of the init command
 type InitOutput struct {
 	Raw                  string
 	InitializedProviders []Provider
+	InstalledModules     []InstalledModule
+	// Backend is the type of the configured backend, like "s3"
+	Backend string
+	// StateMigrated is set when init ran with -force-copy or -migrate-state
+	// and the backend recorded in .terraform changed, so the state was copied
+	// to the new backend
+	StateMigrated bool
+	// AlreadyInitialized is set when the directory had been initialized before
+	AlreadyInitialized bool
+	Attempts           []Attempt
 }
 
 // InitError represents an error on the Init command
@@ -60,26 +115,241 @@ func (e InitError) Error() string {
 	return e.Code
 }
 
-// Init executes the 'terraform init' command
-func (t *Terralib) Init(options []string) (InitOutput, error) {
-	cmdString := formatCommand("init", options)
-	cmd := exec.Command("sh", "-c", cmdString)
-	cmd.Dir = t.ConfigPath
-	stdOutputError, _ := cmd.CombinedOutput()
+// Init executes the 'terraform init' command, configuring Terralib.Backend
+// when it is set
+func (t *Terralib) Init(options []string) (initOutput InitOutput, err error) {
+	options = t.inputOptions(options)
+	if t.Backend != nil {
+		backendOptions, cleanup, err := t.backendOptions()
+		defer cleanup()
+		if err != nil {
+			return InitOutput{}, err
+		}
+		options = append(backendOptions, options...)
+	}
+	if err := t.beforeCommand(CommandInit, options); err != nil {
+		return InitOutput{}, err
+	}
+	defer func() {
+		t.afterCommand(CommandInit, options, initOutput, err)
+	}()
+	_, statErr := os.Stat(t.path(dataDir))
+	previousBackend := t.recordedBackend()
+	_, stateErr := os.Stat(t.path(StateFileName))
+	stdOutputError, match, attempts := t.execute(CommandInit, options, nil)
 	initProviders := getProvidersFromOutput(stdOutputError)
-	initError := findInitError(stdOutputError)
+	if lock, err := LoadLockFile(t.path(LockFileName)); err == nil {
+		for i, provider := range initProviders {
+			if locked := lock.Provider(provider.Source); locked != nil && provider.Source != "" {
+				initProviders[i].Hashes = locked.Hashes
+			}
+		}
+	}
+	initError := initErrorFrom(match)
 	return InitOutput{
 		Raw:                  string(stdOutputError),
 		InitializedProviders: initProviders,
+		InstalledModules:     t.installedModules(stdOutputError),
+		Backend:              t.backendType(stdOutputError),
+		StateMigrated:        match == nil && migratesState(options) && t.backendChanged(previousBackend, stateErr == nil),
+		AlreadyInitialized:   statErr == nil,
+		Attempts:             attempts,
 	}, initError
 }
 
+// dataDir is the directory where init installs providers and modules
+const dataDir = ".terraform"
+
+var (
+	moduleDownloadingRegexp = regexp.MustCompile(`^Downloading (\S+)(?: (\S+))? for (\S+?)\.\.\.$`)
+	moduleDirRegexp         = regexp.MustCompile(`^- (\S+) in (.+)$`)
+	backendRegexp           = regexp.MustCompile(`Successfully configured the backend "([^"]+)"`)
+)
+
+// modulesManifest represents the .terraform/modules/modules.json file
+type modulesManifest struct {
+	Modules []struct {
+		Key     string
+		Source  string
+		Version string
+		Dir     string
+	}
+}
+
+// installedModules returns the modules installed by init, completed with the
+// modules manifest written by terraform
+func (t *Terralib) installedModules(output []byte) []InstalledModule {
+	modules := getModulesFromOutput(output)
+	if len(modules) == 0 {
+		return nil
+	}
+	data, err := ioutil.ReadFile(t.path(filepath.Join(dataDir, "modules", "modules.json")))
+	if err != nil {
+		return modules
+	}
+	var manifest modulesManifest
+	if err := json.Unmarshal(data, &manifest); err != nil {
+		return modules
+	}
+	for i := range modules {
+		for _, m := range manifest.Modules {
+			if m.Key != modules[i].Name {
+				continue
+			}
+			if modules[i].Source == "" {
+				modules[i].Source = m.Source
+			}
+			if modules[i].Version == "" {
+				modules[i].Version = m.Version
+			}
+			if modules[i].Dir == "" {
+				modules[i].Dir = m.Dir
+			}
+		}
+	}
+	return modules
+}
+
+func getModulesFromOutput(out []byte) []InstalledModule {
+	var modules []InstalledModule
+	index := map[string]int{}
+	module := func(name string) *InstalledModule {
+		i, ok := index[name]
+		if !ok {
+			i = len(modules)
+			index[name] = i
+			modules = append(modules, InstalledModule{Name: name})
+		}
+		return &modules[i]
+	}
+	inModules := false
+	scanner := bufio.NewScanner(bytes.NewReader(out))
+	for scanner.Scan() {
+		line := scanner.Text()
+		switch {
+		case line == "Initializing modules..." || line == "Upgrading modules...":
+			inModules = true
+		case strings.HasPrefix(line, "Initializing "):
+			inModules = false
+		case !inModules:
+		case moduleDownloadingRegexp.MatchString(line):
+			m := moduleDownloadingRegexp.FindStringSubmatch(line)
+			installed := module(m[3])
+			installed.Source = m[1]
+			installed.Version = m[2]
+		case moduleDirRegexp.MatchString(line):
+			m := moduleDirRegexp.FindStringSubmatch(line)
+			module(m[1]).Dir = m[2]
+		}
+	}
+	return modules
+}
+
+func getBackendFromOutput(out []byte) string {
+	if m := backendRegexp.FindSubmatch(out); m != nil {
+		return string(m[1])
+	}
+	return ""
+}
+
+// backendType returns the type of the backend configured by init, or else the
+// one recorded by a previous init
+func (t *Terralib) backendType(output []byte) string {
+	if backend := getBackendFromOutput(output); backend != "" {
+		return backend
+	}
+	if backend := t.recordedBackend(); backend != nil {
+		return backend.Type
+	}
+	return ""
+}
+
+// recordedBackend represents the backend recorded by init in
+// .terraform/terraform.tfstate. Hash changes with its configuration
+type recordedBackend struct {
+	Type string          `json:"type"`
+	Hash json.RawMessage `json:"hash"`
+}
+
+// recordedBackend returns the backend recorded by the last init, nil when
+// there is none
+func (t *Terralib) recordedBackend() *recordedBackend {
+	data, err := ioutil.ReadFile(t.path(filepath.Join(dataDir, "terraform.tfstate")))
+	if err != nil {
+		return nil
+	}
+	var backendState struct {
+		Backend *recordedBackend `json:"backend"`
+	}
+	if err := json.Unmarshal(data, &backendState); err != nil {
+		return nil
+	}
+	return backendState.Backend
+}
+
+// backendChanged tells whether init configured another backend than previous,
+// or a backend replacing a local state when there was none
+func (t *Terralib) backendChanged(previous *recordedBackend, localState bool) bool {
+	current := t.recordedBackend()
+	if current == nil {
+		return false
+	}
+	if previous == nil {
+		return localState
+	}
+	return current.Type != previous.Type || string(current.Hash) != string(previous.Hash)
+}
+
+// migratesState tells whether the init options copy the state to a changed
+// backend
+func migratesState(options []string) bool {
+	for _, option := range options {
+		if option == "-force-copy" || option == "-migrate-state" || option == "-migrate-state=true" {
+			return true
+		}
+	}
+	return false
+}
+
+var (
+	providerInstallingRegexp = regexp.MustCompile(`^- Installing (\S+) v(\S+?)\.\.\.$`)
+	providerInstalledRegexp  = regexp.MustCompile(`^- Installed (\S+) v(\S+) \((.*)\)$`)
+	providerUsingRegexp      = regexp.MustCompile(`^- Using (?:previously-installed )?(\S+) v(\S+)(?: from the shared cache directory)?$`)
+	providerReusingRegexp    = regexp.MustCompile(`^- Reusing previous version of (\S+) from the dependency lock file$`)
+)
+
 func getProvidersFromOutput(out []byte) []Provider {
 	var providers []Provider
+	// index of the providers of terraform 0.13+ by source address
+	index := map[string]int{}
+	provider := func(source string) *Provider {
+		i, ok := index[source]
+		if !ok {
+			i = len(providers)
+			index[source] = i
+			providers = append(providers, Provider{
+				Name:   source[strings.LastIndex(source, "/")+1:],
+				Source: source,
+			})
+		}
+		return &providers[i]
+	}
 	scanner := bufio.NewScanner(bytes.NewReader(out))
 	for scanner.Scan() {
 		line := scanner.Text()
-		if strings.HasPrefix(line, "- Downloading plugin for provider") {
+		if m := providerReusingRegexp.FindStringSubmatch(line); m != nil {
+			provider(m[1]).Locked = true
+		} else if m := providerInstallingRegexp.FindStringSubmatch(line); m != nil {
+			provider(m[1]).Version = m[2]
+		} else if m := providerInstalledRegexp.FindStringSubmatch(line); m != nil {
+			p := provider(m[1])
+			p.Version = m[2]
+			p.Signature, p.Signer, p.KeyID = parseProviderAuthentication(m[3])
+		} else if m := providerUsingRegexp.FindStringSubmatch(line); m != nil {
+			p := provider(m[1])
+			p.Version = m[2]
+			p.Reused = true
+		} else if strings.HasPrefix(line, "- Downloading plugin for provider") {
 			var name string
 			var path string
 			var version string
@@ -95,16 +365,29 @@ func getProvidersFromOutput(out []byte) []Provider {
 	return providers
 }
 
+// parseProviderAuthentication reads how a provider package was authenticated,
+// like "signed by a HashiCorp partner, key ID 38027F80D7FD5FB2"
+func parseProviderAuthentication(text string) (signature string, signer string, keyID string) {
+	if i := strings.Index(text, ", key ID "); i >= 0 {
+		keyID = text[i+len(", key ID "):]
+		text = text[:i]
+	}
+	if strings.HasPrefix(text, "signed by ") {
+		return "signed", strings.TrimPrefix(text, "signed by "), keyID
+	}
+	return text, "", keyID
+}
+
 func findInitError(output []byte) error {
-	for k, v := range initErrors {
-		r := regexp.MustCompile(v.Error())
-		line := r.Find(output)
-		if line != nil {
-			return InitError{
-				Reason: strings.TrimRight(string(line), "."),
-				Code:   k,
-			}
-		}
+	return initErrorFrom(DefaultClassifiers.Classify(CommandInit, "", output))
+}
+
+func initErrorFrom(match *Match) error {
+	if match == nil {
+		return nil
+	}
+	return InitError{
+		Reason: strings.TrimRight(match.Reason, "."),
+		Code:   match.Code,
 	}
-	return nil
 }
@@ -1,8 +1,12 @@
 package terralib
 
 import (
+	"context"
 	"fmt"
+	"io/ioutil"
+	"os"
 	"os/exec"
+	"path/filepath"
 	"testing"
 
 	"github.com/google/go-cmp/cmp"
@@ -335,3 +339,191 @@ func getTerraformVersion() (string, error) {
 	}
 	return version, err
 }
+
+const initOutputProvidersTest string = `
+Initializing the backend...
+
+Initializing provider plugins...
+- Reusing previous version of hashicorp/aws from the dependency lock file
+- Reusing previous version of hashicorp/random from the dependency lock file
+- Finding latest version of acme/internal...
+- Finding latest version of example/legacy...
+- Using previously-installed hashicorp/aws v5.1.0
+- Installing hashicorp/random v3.5.1...
+- Installed hashicorp/random v3.5.1 (signed by HashiCorp)
+- Installing acme/internal v0.4.2...
+- Installed acme/internal v0.4.2 (self-signed, key ID 5B1E3C6D7A8F9012)
+- Installing example/legacy v1.0.0...
+- Installed example/legacy v1.0.0 (unauthenticated)
+- Using hashicorp/null v3.2.1 from the shared cache directory
+
+Terraform has been successfully initialized!
+`
+
+func TestGetProvidersFromOutputModern(t *testing.T) {
+	expected := []Provider{
+		{
+			Name:    "aws",
+			Version: "5.1.0",
+			Source:  "hashicorp/aws",
+			Locked:  true,
+			Reused:  true,
+		},
+		{
+			Name:      "random",
+			Version:   "3.5.1",
+			Source:    "hashicorp/random",
+			Locked:    true,
+			Signature: "signed",
+			Signer:    "HashiCorp",
+		},
+		{
+			Name:      "internal",
+			Version:   "0.4.2",
+			Source:    "acme/internal",
+			Signature: "self-signed",
+			KeyID:     "5B1E3C6D7A8F9012",
+		},
+		{
+			Name:      "legacy",
+			Version:   "1.0.0",
+			Source:    "example/legacy",
+			Signature: "unauthenticated",
+		},
+		{
+			Name:    "null",
+			Version: "3.2.1",
+			Source:  "hashicorp/null",
+			Reused:  true,
+		},
+	}
+	got := getProvidersFromOutput([]byte(initOutputProvidersTest))
+	if !cmp.Equal(got, expected) {
+		t.Errorf("Got: %+v, Expected: %+v", got, expected)
+	}
+}
+
+// executorFunc runs every command with a function
+type executorFunc func(ctx context.Context, inv Invocation) (Result, error)
+
+func (f executorFunc) Execute(ctx context.Context, inv Invocation) (Result, error) {
+	return f(ctx, inv)
+}
+
+// failingExecutor prints output and exits with 1 for the first failures runs,
+// then succeeds, counting the runs
+func failingExecutor(output string, failures int, runs *int) Executor {
+	return executorFunc(func(ctx context.Context, inv Invocation) (Result, error) {
+		*runs++
+		if *runs <= failures {
+			return Result{Output: []byte(output), ExitCode: 1}, nil
+		}
+		return Result{}, nil
+	})
+}
+
+const initOutputModulesTest string = `
+Initializing modules...
+- app in modules/app
+- app.alarms in modules/app/modules/alarms
+
+Initializing the backend...
+
+Initializing provider plugins...
+
+Terraform has been successfully initialized!
+`
+
+const modulesManifestTest string = `{"Modules":[
+{"Key":"","Source":"","Dir":"."},
+{"Key":"app","Source":"./modules/app","Dir":"modules/app"},
+{"Key":"app.alarms","Source":"./modules/alarms","Dir":"modules/app/modules/alarms"},
+{"Key":"vpc","Source":"terraform-aws-modules/vpc/aws","Version":"2.33.0","Dir":".terraform/modules/vpc"}
+]}`
+
+func TestInitModulesAndBackend(t *testing.T) {
+	dir, err := ioutil.TempDir("", "terralib")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	if err := os.MkdirAll(filepath.Join(dir, ".terraform", "modules"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	ioutil.WriteFile(filepath.Join(dir, ".terraform", "modules", "modules.json"), []byte(modulesManifestTest), 0644)
+	ioutil.WriteFile(filepath.Join(dir, ".terraform", "terraform.tfstate"), []byte(`{"version":3,"backend":{"type":"gcs"}}`), 0644)
+
+	tf := Terralib{
+		ConfigPath: dir,
+		Executor: executorFunc(func(ctx context.Context, inv Invocation) (Result, error) {
+			return Result{Output: []byte(initOutputModulesTest)}, nil
+		}),
+	}
+	output, err := tf.Init(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []InstalledModule{
+		{Name: "app", Source: "./modules/app", Dir: "modules/app"},
+		{Name: "app.alarms", Source: "./modules/alarms", Dir: "modules/app/modules/alarms"},
+	}
+	if !cmp.Equal(output.InstalledModules, expected) {
+		t.Errorf("Got: %+v, Expected: %+v", output.InstalledModules, expected)
+	}
+	if output.Backend != "gcs" {
+		t.Errorf("Got: %q, Expected the backend recorded by the previous init", output.Backend)
+	}
+	if !output.AlreadyInitialized {
+		t.Errorf("Expected the directory to be already initialized")
+	}
+	if output.StateMigrated {
+		t.Errorf("Expected no state migration")
+	}
+}
+
+func TestInitStateMigrated(t *testing.T) {
+	tests := []struct {
+		name       string
+		previous   string
+		localState bool
+		options    []string
+		output     string
+		expected   bool
+	}{
+		{"new backend", `{"type":"gcs","hash":1}`, false, []string{"-force-copy"}, "", true},
+		{"new configuration", `{"type":"s3","hash":1}`, false, []string{"-migrate-state"}, "", true},
+		{"local state", "", true, []string{"-force-copy"}, "", true},
+		{"no state", "", false, []string{"-force-copy"}, "", false},
+		{"same backend", `{"type":"s3","hash":2}`, false, []string{"-force-copy"}, "", false},
+		{"reconfigure", `{"type":"gcs","hash":1}`, false, []string{"-reconfigure"}, "", false},
+		{"failed", `{"type":"gcs","hash":1}`, false, []string{"-force-copy"}, "Error: Backend configuration changed\n", false},
+	}
+	for _, test := range tests {
+		dir, err := ioutil.TempDir("", "terralib")
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer os.RemoveAll(dir)
+		backendState := filepath.Join(dir, ".terraform", "terraform.tfstate")
+		os.MkdirAll(filepath.Dir(backendState), 0755)
+		if test.previous != "" {
+			ioutil.WriteFile(backendState, []byte(`{"version":3,"backend":`+test.previous+`}`), 0644)
+		}
+		if test.localState {
+			ioutil.WriteFile(filepath.Join(dir, StateFileName), []byte(`{"version":4}`), 0644)
+		}
+		tf := Terralib{
+			ConfigPath: dir,
+			Executor: executorFunc(func(ctx context.Context, inv Invocation) (Result, error) {
+				if test.output == "" {
+					ioutil.WriteFile(backendState, []byte(`{"version":3,"backend":{"type":"s3","hash":2}}`), 0644)
+				}
+				return Result{Output: []byte(test.output)}, nil
+			}),
+		}
+		output, _ := tf.Init(test.options)
+		if output.StateMigrated != test.expected {
+			t.Errorf("%s: Got: %v, Expected: %v", test.name, output.StateMigrated, test.expected)
+		}
+	}
+}
@@ -0,0 +1,157 @@
+package terralib
+
+import (
+	"bufio"
+	"bytes"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Exported error codes
+const (
+	ErrStateLocked        string = "errStateLocked"
+	ErrAcquiringStateLock string = "errAcquiringStateLock"
+	ErrUnlockFailed       string = "errUnlockFailed"
+	ErrLocalStateUnlock   string = "errLocalStateUnlock"
+	ErrInvalidLockID      string = "errInvalidLockID"
+	ErrForceUnlockDefault string = "errForceUnlockDefault"
+)
+
+// lockIDRegexp matches the lock IDs of the backends, like UUIDs or hex
+// digests, and nothing the shell would interpret
+var lockIDRegexp = regexp.MustCompile(`^[A-Za-z0-9._:/-]+$`)
+
+// defaultLockPollInterval is used when Terralib.LockPollInterval is not set
+const defaultLockPollInterval = 10 * time.Second
+
+var lockClassifiers = []Classifier{
+	{
+		Code: ErrStateLocked,
+		Pattern: regexp.MustCompile(`(?P<reason>Error acquiring the state lock)(?s:.*?)Lock Info:\s*\n` +
+			`\s*ID:\s+(?P<lock_id>\S+)`),
+		Priority: 10,
+		Commands: []string{CommandPlan, CommandApply, CommandDestroy},
+	},
+	NewClassifier(ErrAcquiringStateLock, `(?P<reason>Error acquiring the state lock[^\n]*)`, CommandPlan, CommandApply, CommandDestroy),
+	NewClassifier(ErrUnlockFailed, `(?P<reason>Failed to unlock state[^\n]*?)\.?\n`, CommandForceUnlock),
+	NewClassifier(ErrLocalStateUnlock, `Local state cannot be unlocked by another process`, CommandForceUnlock),
+	defaultClassifier(ErrForceUnlockDefault, CommandForceUnlock),
+}
+
+// LockInfo represents the information of a state lock held by another process
+type LockInfo struct {
+	ID        string
+	Path      string
+	Operation string
+	Who       string
+	Version   string
+	Created   string
+	Info      string
+}
+
+// ForceUnlockOutput represents the output of the force-unlock command
+type ForceUnlockOutput struct {
+	Raw string
+}
+
+// ForceUnlockError represents an error on the ForceUnlock command
+type ForceUnlockError struct {
+	Reason string
+	Code   string
+}
+
+func (e ForceUnlockError) Error() string {
+	return e.Code
+}
+
+// ForceUnlock executes the 'terraform force-unlock' command, removing the
+// state lock with the given ID without asking for confirmation. As the ID may
+// come from the metadata of a remote backend, it fails with ErrInvalidLockID
+// without running terraform when the ID holds other characters than letters,
+// digits and "._:/-"
+func (t *Terralib) ForceUnlock(lockID string) (output ForceUnlockOutput, err error) {
+	options := []string{
+		"-force",
+		lockID,
+	}
+	if err := t.beforeCommand(CommandForceUnlock, options); err != nil {
+		return ForceUnlockOutput{}, err
+	}
+	defer func() {
+		t.afterCommand(CommandForceUnlock, options, output, err)
+	}()
+	if !lockIDRegexp.MatchString(lockID) {
+		return ForceUnlockOutput{}, ForceUnlockError{
+			Reason: "Invalid lock ID " + strconv.Quote(lockID),
+			Code:   ErrInvalidLockID,
+		}
+	}
+	stdOutputError, match, _ := t.execute(CommandForceUnlock, options, nil)
+	unlockError := forceUnlockErrorFrom(match)
+	return ForceUnlockOutput{
+		Raw: string(stdOutputError),
+	}, unlockError
+}
+
+func forceUnlockErrorFrom(match *Match) error {
+	if match == nil {
+		return nil
+	}
+	return ForceUnlockError{
+		Reason: match.Reason,
+		Code:   match.Code,
+	}
+}
+
+func (t *Terralib) lockPollInterval() time.Duration {
+	if t.LockPollInterval > 0 {
+		return t.LockPollInterval
+	}
+	return defaultLockPollInterval
+}
+
+func lockInfoFrom(match *Match, output []byte) *LockInfo {
+	if match.Code != ErrStateLocked {
+		return nil
+	}
+	return parseLockInfo(output)
+}
+
+// parseLockInfo reads the "Lock Info:" block printed by terraform when the
+// state is locked
+func parseLockInfo(output []byte) *LockInfo {
+	start := bytes.Index(output, []byte("Lock Info:"))
+	if start < 0 {
+		return nil
+	}
+	var lock LockInfo
+	scanner := bufio.NewScanner(bytes.NewReader(output[start:]))
+	scanner.Scan()
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		i := strings.Index(line, ":")
+		if i < 0 {
+			break
+		}
+		value := strings.TrimSpace(line[i+1:])
+		switch line[:i] {
+		case "ID":
+			lock.ID = value
+		case "Path":
+			lock.Path = value
+		case "Operation":
+			lock.Operation = value
+		case "Who":
+			lock.Who = value
+		case "Version":
+			lock.Version = value
+		case "Created":
+			lock.Created = value
+		case "Info":
+			lock.Info = value
+		}
+	}
+	return &lock
+}
@@ -0,0 +1,150 @@
+package terralib
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+const planOutputErrStateLockedTest string = `
+Acquiring state lock. This may take a few moments...
+
+Error: Error locking state: Error acquiring the state lock: ConditionalCheckFailedException: The conditional request failed
+	status code: 400, request id: 4H7Q2L0B6S8E0D7K9P1M3N5R7T9V1X3Z
+Lock Info:
+  ID:        6b4a25a8-3f1e-09c4-c1b2-1e5c0a7c9d02
+  Path:      terraform-state/dev/terraform.tfstate
+  Operation: OperationTypePlan
+  Who:       alice@laptop
+  Version:   0.12.24
+  Created:   2020-04-10 08:21:05.119284 +0000 UTC
+  Info:      nightly drift check
+
+
+Terraform acquires a state lock to protect the state from being written
+by multiple users at the same time. Please resolve the issue above and try
+again. For most commands, you can disable locking with the "-lock=false"
+flag, but this is not recommended.
+`
+
+const forceUnlockOutputErrUnlockFailedTest string = `
+Failed to unlock state: failed to retrieve lock info: unexpected end of JSON input
+`
+
+func TestFindPlanErrStateLocked(t *testing.T) {
+	expected := PlanError{
+		Reason: "Error acquiring the state lock",
+		Code:   "errStateLocked",
+		Lock: &LockInfo{
+			ID:        "6b4a25a8-3f1e-09c4-c1b2-1e5c0a7c9d02",
+			Path:      "terraform-state/dev/terraform.tfstate",
+			Operation: "OperationTypePlan",
+			Who:       "alice@laptop",
+			Version:   "0.12.24",
+			Created:   "2020-04-10 08:21:05.119284 +0000 UTC",
+			Info:      "nightly drift check",
+		},
+	}
+	got := findPlanError([]byte(planOutputErrStateLockedTest))
+	if !cmp.Equal(got, expected) {
+		t.Errorf("Got: %+v, Expected: %+v", got, expected)
+	}
+}
+
+func TestFindForceUnlockErrUnlockFailed(t *testing.T) {
+	expected := ForceUnlockError{
+		Reason: "Failed to unlock state: failed to retrieve lock info: unexpected end of JSON input",
+		Code:   "errUnlockFailed",
+	}
+	got := forceUnlockErrorFrom(DefaultClassifiers.Classify(CommandForceUnlock, "", []byte(forceUnlockOutputErrUnlockFailedTest)))
+	if !cmp.Equal(got, expected) {
+		t.Errorf("Got: %+v, Expected: %+v", got, expected)
+	}
+}
+
+func TestPlanWaitsForLock(t *testing.T) {
+	sleeps := recordSleeps(t)
+	runs := 0
+	tf := Terralib{
+		Executor:         failingExecutor(planOutputErrStateLockedTest, 2, &runs),
+		LockWait:         time.Hour,
+		LockPollInterval: 10 * time.Second,
+	}
+	output, err := tf.Plan(nil)
+	if err != nil {
+		t.Fatalf("Got error: %v", err)
+	}
+	if runs != 3 || len(output.Attempts) != 3 || output.Attempts[0].Code != ErrStateLocked {
+		t.Errorf("Got %d runs and attempts %+v, Expected 3", runs, output.Attempts)
+	}
+	if expected := []time.Duration{10 * time.Second, 10 * time.Second}; !cmp.Equal(*sleeps, expected) {
+		t.Errorf("Got sleeps: %v, Expected: %v", *sleeps, expected)
+	}
+}
+
+func TestPlanLockWaitExpires(t *testing.T) {
+	runs := 0
+	tf := Terralib{
+		Executor:         failingExecutor(planOutputErrStateLockedTest, 1000, &runs),
+		LockWait:         50 * time.Millisecond,
+		LockPollInterval: 20 * time.Millisecond,
+	}
+	start := time.Now()
+	_, err := tf.Plan(nil)
+	planErr, ok := err.(PlanError)
+	if !ok || planErr.Code != ErrStateLocked || planErr.Lock == nil || planErr.Lock.ID != "6b4a25a8-3f1e-09c4-c1b2-1e5c0a7c9d02" {
+		t.Errorf("Got: %+v, Expected: %s with the lock info", err, ErrStateLocked)
+	}
+	if elapsed := time.Since(start); elapsed < 50*time.Millisecond || runs > 5 {
+		t.Errorf("Got %d runs in %v, Expected to poll for 50ms", runs, elapsed)
+	}
+	runs = 0
+	tf.LockWait = 0
+	if _, err := tf.Plan(nil); err == nil || runs != 1 {
+		t.Errorf("Got %d runs, Expected to fail at once without LockWait", runs)
+	}
+}
+
+func TestForceUnlock(t *testing.T) {
+	var invocations []Invocation
+	tf := Terralib{
+		ConfigPath: "/tmp/config",
+		Executor: executorFunc(func(ctx context.Context, inv Invocation) (Result, error) {
+			invocations = append(invocations, inv)
+			return Result{Output: []byte("Terraform state has been successfully unlocked!")}, nil
+		}),
+	}
+	if _, err := tf.ForceUnlock("6b4a25a8-3f1e-09c4-c1b2-1e5c0a7c9d02"); err != nil {
+		t.Fatalf("Got error: %v", err)
+	}
+	expected := []string{"force-unlock", "-force", "6b4a25a8-3f1e-09c4-c1b2-1e5c0a7c9d02"}
+	if len(invocations) != 1 || !cmp.Equal(invocations[0].Args, expected) || invocations[0].Dir != "/tmp/config" {
+		t.Errorf("Got: %+v, Expected: terraform %v", invocations, expected)
+	}
+}
+
+func TestForceUnlockInvalidID(t *testing.T) {
+	executed := false
+	tf := Terralib{
+		Executor: executorFunc(func(ctx context.Context, inv Invocation) (Result, error) {
+			executed = true
+			return Result{}, nil
+		}),
+	}
+	for _, lockID := range []string{"$(touch /tmp/pwned)", "abc; rm -rf /", "a b", "`id`", ""} {
+		_, err := tf.ForceUnlock(lockID)
+		if unlockErr, ok := err.(ForceUnlockError); !ok || unlockErr.Code != ErrInvalidLockID {
+			t.Errorf("%q: Got: %v, Expected: %s", lockID, err, ErrInvalidLockID)
+		}
+	}
+	if executed {
+		t.Errorf("ForceUnlock ran terraform with an invalid lock ID")
+	}
+	for _, lockID := range []string{"6b4a25a8-3f1e-09c4-c1b2-1e5c0a7c9d02", "acme-state/prod/terraform.tfstate-md5", "1a2b3c4d"} {
+		if _, err := tf.ForceUnlock(lockID); err != nil {
+			t.Errorf("%q: Got error: %v", lockID, err)
+		}
+	}
+}
@@ -0,0 +1,323 @@
+package terralib
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Exported error codes
+const (
+	ErrProvidersLockDefault string = "errProvidersLockDefault"
+)
+
+var providersLockClassifiers = []Classifier{
+	defaultClassifier(ErrProvidersLockDefault, CommandProvidersLock),
+}
+
+// LockFileName is the name of the dependency lock file of terraform 0.14+
+const LockFileName = ".terraform.lock.hcl"
+
+const lockFileHeader = `# This file is maintained automatically by "terraform init".
+# Manual edits may be lost in future updates.
+`
+
+// defaultRegistry is the hostname of provider source addresses without one
+const defaultRegistry = "registry.terraform.io"
+
+// LockFile represents a dependency lock file
+type LockFile struct {
+	Providers []LockedProvider
+}
+
+// LockedProvider represents the provider selection recorded in a lock file
+type LockedProvider struct {
+	// Source is the full source address, like "registry.terraform.io/hashicorp/aws"
+	Source      string
+	Version     string
+	Constraints string
+	// Hashes are the checksums of the packages, like "h1:..." or "zh:..."
+	Hashes []string
+}
+
+// LockChange represents the change of a provider between two lock files
+type LockChange struct {
+	Source         string
+	Action         string
+	OldVersion     string
+	NewVersion     string
+	OldConstraints string
+	NewConstraints string
+	AddedHashes    []string
+	RemovedHashes  []string
+}
+
+// ProvidersLockOutput represents the output of the providers lock command
+type ProvidersLockOutput struct {
+	Raw string
+	// Lock is the lock file after the command, Changes what it changed
+	Lock    *LockFile
+	Changes []LockChange
+}
+
+// ProvidersLockError represents an error on the ProvidersLock command
+type ProvidersLockError struct {
+	Reason string
+	Code   string
+}
+
+func (e ProvidersLockError) Error() string {
+	return e.Code
+}
+
+// LoadLockFile reads a dependency lock file
+func LoadLockFile(path string) (*LockFile, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	lock, err := ParseLockFile(data)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+	return lock, nil
+}
+
+// ParseLockFile parses the contents of a dependency lock file. Only the
+// layout written by terraform is supported, not every HCL construct
+func ParseLockFile(data []byte) (*LockFile, error) {
+	lock := &LockFile{}
+	var provider *LockedProvider
+	inHashes := false
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimSpace(scanner.Text())
+		switch {
+		case line == "" || strings.HasPrefix(line, "#") || strings.HasPrefix(line, "//"):
+		case inHashes:
+			end := strings.HasSuffix(line, "]")
+			hashes, err := quotedList(strings.TrimSuffix(line, "]"))
+			if err != nil {
+				return nil, fmt.Errorf("line %d: %v", n, err)
+			}
+			provider.Hashes = append(provider.Hashes, hashes...)
+			inHashes = !end
+		case provider == nil:
+			fields := strings.Fields(line)
+			if len(fields) != 3 || fields[0] != "provider" || fields[2] != "{" {
+				return nil, fmt.Errorf("line %d: expected a provider block, got %q", n, line)
+			}
+			source, err := strconv.Unquote(fields[1])
+			if err != nil {
+				return nil, fmt.Errorf("line %d: invalid provider source %s", n, fields[1])
+			}
+			lock.Providers = append(lock.Providers, LockedProvider{Source: source})
+			provider = &lock.Providers[len(lock.Providers)-1]
+		case line == "}":
+			provider = nil
+		default:
+			i := strings.Index(line, "=")
+			if i < 0 {
+				return nil, fmt.Errorf("line %d: expected an argument, got %q", n, line)
+			}
+			name, value := strings.TrimSpace(line[:i]), strings.TrimSpace(line[i+1:])
+			if name == "hashes" {
+				if !strings.HasPrefix(value, "[") {
+					return nil, fmt.Errorf("line %d: expected a list of hashes", n)
+				}
+				end := strings.HasSuffix(value, "]")
+				hashes, err := quotedList(strings.TrimSuffix(strings.TrimPrefix(value, "["), "]"))
+				if err != nil {
+					return nil, fmt.Errorf("line %d: %v", n, err)
+				}
+				provider.Hashes = append(provider.Hashes, hashes...)
+				inHashes = !end
+				continue
+			}
+			unquoted, err := strconv.Unquote(value)
+			if err != nil {
+				return nil, fmt.Errorf("line %d: invalid value of %s", n, name)
+			}
+			switch name {
+			case "version":
+				provider.Version = unquoted
+			case "constraints":
+				provider.Constraints = unquoted
+			}
+		}
+	}
+	if provider != nil {
+		return nil, fmt.Errorf("provider block %q is not closed", provider.Source)
+	}
+	return lock, nil
+}
+
+// quotedList parses comma separated strings, like `"h1:abc", "zh:def",`
+func quotedList(text string) ([]string, error) {
+	var values []string
+	for _, item := range strings.Split(text, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+		value, err := strconv.Unquote(item)
+		if err != nil {
+			return nil, fmt.Errorf("invalid hash %s", item)
+		}
+		values = append(values, value)
+	}
+	return values, nil
+}
+
+// Bytes returns the lock file in the layout written by terraform
+func (l *LockFile) Bytes() []byte {
+	providers := append([]LockedProvider(nil), l.Providers...)
+	sort.Slice(providers, func(i, j int) bool {
+		return providers[i].Source < providers[j].Source
+	})
+	var b bytes.Buffer
+	b.WriteString(lockFileHeader)
+	for _, p := range providers {
+		fmt.Fprintf(&b, "\nprovider %q {\n", p.Source)
+		if p.Constraints != "" {
+			fmt.Fprintf(&b, "  version     = %q\n", p.Version)
+			fmt.Fprintf(&b, "  constraints = %q\n", p.Constraints)
+		} else {
+			fmt.Fprintf(&b, "  version = %q\n", p.Version)
+		}
+		hashes := append([]string(nil), p.Hashes...)
+		sort.Strings(hashes)
+		b.WriteString("  hashes = [\n")
+		for _, hash := range hashes {
+			fmt.Fprintf(&b, "    %q,\n", hash)
+		}
+		b.WriteString("  ]\n}\n")
+	}
+	return b.Bytes()
+}
+
+// Save writes the lock file
+func (l *LockFile) Save(path string) error {
+	return ioutil.WriteFile(path, l.Bytes(), 0644)
+}
+
+// Provider returns the provider with the given source address, which may
+// leave out the registry hostname, or nil if it is not locked
+func (l *LockFile) Provider(source string) *LockedProvider {
+	source = fullProviderSource(source)
+	for i := range l.Providers {
+		if l.Providers[i].Source == source {
+			return &l.Providers[i]
+		}
+	}
+	return nil
+}
+
+// fullProviderSource adds the default registry hostname to a source address
+func fullProviderSource(source string) string {
+	if strings.Count(source, "/") == 1 {
+		return defaultRegistry + "/" + source
+	}
+	return source
+}
+
+// DiffLockFiles returns the providers added, removed or changed between two
+// lock files, by source address. Either may be nil when there is no lock file
+func DiffLockFiles(old *LockFile, new *LockFile) []LockChange {
+	if old == nil {
+		old = &LockFile{}
+	}
+	if new == nil {
+		new = &LockFile{}
+	}
+	var changes []LockChange
+	for _, o := range old.Providers {
+		n := new.Provider(o.Source)
+		if n == nil {
+			changes = append(changes, LockChange{
+				Source:         o.Source,
+				Action:         ActionDelete,
+				OldVersion:     o.Version,
+				OldConstraints: o.Constraints,
+				RemovedHashes:  o.Hashes,
+			})
+			continue
+		}
+		change := LockChange{
+			Source:         o.Source,
+			Action:         ActionUpdate,
+			OldVersion:     o.Version,
+			NewVersion:     n.Version,
+			OldConstraints: o.Constraints,
+			NewConstraints: n.Constraints,
+			AddedHashes:    missing(n.Hashes, o.Hashes),
+			RemovedHashes:  missing(o.Hashes, n.Hashes),
+		}
+		if change.OldVersion != change.NewVersion || change.OldConstraints != change.NewConstraints ||
+			len(change.AddedHashes) > 0 || len(change.RemovedHashes) > 0 {
+			changes = append(changes, change)
+		}
+	}
+	for _, n := range new.Providers {
+		if old.Provider(n.Source) == nil {
+			changes = append(changes, LockChange{
+				Source:         n.Source,
+				Action:         ActionCreate,
+				NewVersion:     n.Version,
+				NewConstraints: n.Constraints,
+				AddedHashes:    n.Hashes,
+			})
+		}
+	}
+	sort.SliceStable(changes, func(i, j int) bool {
+		return changes[i].Source < changes[j].Source
+	})
+	return changes
+}
+
+// missing returns the values of a that are not in b
+func missing(a []string, b []string) []string {
+	var values []string
+	for _, value := range a {
+		if !contains(b, value) {
+			values = append(values, value)
+		}
+	}
+	return values
+}
+
+// ProvidersLock executes the 'terraform providers lock' command, adding the
+// hashes of the providers for the given platforms, like "linux_amd64", to the
+// lock file. No platform means the current one
+func (t *Terralib) ProvidersLock(platforms ...string) (providersLockOutput ProvidersLockOutput, err error) {
+	var options []string
+	for _, platform := range platforms {
+		options = append(options, "-platform="+platform)
+	}
+	if err := t.beforeCommand(CommandProvidersLock, options); err != nil {
+		return ProvidersLockOutput{}, err
+	}
+	defer func() {
+		t.afterCommand(CommandProvidersLock, options, providersLockOutput, err)
+	}()
+	before, _ := LoadLockFile(t.path(LockFileName))
+	stdOutputError, match, _ := t.execute(CommandProvidersLock, options, nil)
+	providersLockOutput = ProvidersLockOutput{Raw: string(stdOutputError)}
+	if match != nil {
+		return providersLockOutput, ProvidersLockError{
+			Reason: match.Reason,
+			Code:   match.Code,
+		}
+	}
+	after, err := LoadLockFile(t.path(LockFileName))
+	if err != nil {
+		return providersLockOutput, err
+	}
+	providersLockOutput.Lock = after
+	providersLockOutput.Changes = DiffLockFiles(before, after)
+	return providersLockOutput, nil
+}
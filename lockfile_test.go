@@ -0,0 +1,177 @@
+package terralib
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+const lockFileTest string = `# This file is maintained automatically by "terraform init".
+# Manual edits may be lost in future updates.
+
+provider "registry.terraform.io/hashicorp/aws" {
+  version     = "5.1.0"
+  constraints = "~> 5.0"
+  hashes = [
+    "h1:wVwVAyDn4sxl7nSHqHNh4ugdBxA6HvdQV1MmWbDmaDo=",
+    "zh:0a26d6b6d4c9bb3b1f4a7e2f1e2f6f4a3b2c1d0e9f8a7b6c5d4e3f2a1b0c9d8e",
+    "zh:1c6e0a6e4b9e7f1f2a3b4c5d6e7f8a9b0c1d2e3f4a5b6c7d8e9f0a1b2c3d4e5f",
+  ]
+}
+
+provider "registry.terraform.io/hashicorp/random" {
+  version = "3.5.1"
+  hashes = [
+    "h1:VSnd9ZIPyfKHOObuQCaKfnjIHRtR7qTw19Rz8tJxm+k=",
+    "zh:04e3fbd610cb52c1017d282531364b9c53ef72b6bc533acb2a90671957324a64",
+  ]
+}
+`
+
+func TestParseLockFile(t *testing.T) {
+	lock, err := ParseLockFile([]byte(lockFileTest))
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := &LockFile{
+		Providers: []LockedProvider{
+			{
+				Source:      "registry.terraform.io/hashicorp/aws",
+				Version:     "5.1.0",
+				Constraints: "~> 5.0",
+				Hashes: []string{
+					"h1:wVwVAyDn4sxl7nSHqHNh4ugdBxA6HvdQV1MmWbDmaDo=",
+					"zh:0a26d6b6d4c9bb3b1f4a7e2f1e2f6f4a3b2c1d0e9f8a7b6c5d4e3f2a1b0c9d8e",
+					"zh:1c6e0a6e4b9e7f1f2a3b4c5d6e7f8a9b0c1d2e3f4a5b6c7d8e9f0a1b2c3d4e5f",
+				},
+			},
+			{
+				Source:  "registry.terraform.io/hashicorp/random",
+				Version: "3.5.1",
+				Hashes: []string{
+					"h1:VSnd9ZIPyfKHOObuQCaKfnjIHRtR7qTw19Rz8tJxm+k=",
+					"zh:04e3fbd610cb52c1017d282531364b9c53ef72b6bc533acb2a90671957324a64",
+				},
+			},
+		},
+	}
+	if !cmp.Equal(lock, expected) {
+		t.Errorf("Got: %+v, Expected: %+v", lock, expected)
+	}
+	if got := string(lock.Bytes()); got != lockFileTest {
+		t.Errorf("Got:\n%s\nExpected the lock file to be written as terraform does:\n%s", got, lockFileTest)
+	}
+	if p := lock.Provider("hashicorp/random"); p == nil || p.Version != "3.5.1" {
+		t.Errorf("Got: %+v, Expected the provider to be found by its short source address", p)
+	}
+}
+
+func TestParseLockFileErrors(t *testing.T) {
+	tests := []string{
+		"provider hashicorp/aws {\n}\n",
+		"provider \"registry.terraform.io/hashicorp/aws\" {\n  version = 5.1.0\n}\n",
+		"provider \"registry.terraform.io/hashicorp/aws\" {\n  version = \"5.1.0\"\n",
+	}
+	for _, test := range tests {
+		if _, err := ParseLockFile([]byte(test)); err == nil {
+			t.Errorf("Expected an error parsing %q", test)
+		}
+	}
+}
+
+func TestDiffLockFiles(t *testing.T) {
+	old := &LockFile{Providers: []LockedProvider{
+		{Source: "registry.terraform.io/hashicorp/aws", Version: "4.67.0", Constraints: "~> 4.0", Hashes: []string{"h1:old="}},
+		{Source: "registry.terraform.io/hashicorp/null", Version: "3.2.1", Hashes: []string{"h1:null="}},
+		{Source: "registry.terraform.io/hashicorp/random", Version: "3.5.1", Hashes: []string{"h1:random="}},
+	}}
+	new := &LockFile{Providers: []LockedProvider{
+		{Source: "registry.terraform.io/hashicorp/aws", Version: "5.1.0", Constraints: "~> 5.0", Hashes: []string{"h1:new="}},
+		{Source: "registry.terraform.io/hashicorp/random", Version: "3.5.1", Hashes: []string{"h1:random="}},
+		{Source: "registry.terraform.io/integrations/github", Version: "5.26.0", Hashes: []string{"h1:github="}},
+	}}
+	expected := []LockChange{
+		{
+			Source:         "registry.terraform.io/hashicorp/aws",
+			Action:         ActionUpdate,
+			OldVersion:     "4.67.0",
+			NewVersion:     "5.1.0",
+			OldConstraints: "~> 4.0",
+			NewConstraints: "~> 5.0",
+			AddedHashes:    []string{"h1:new="},
+			RemovedHashes:  []string{"h1:old="},
+		},
+		{
+			Source:        "registry.terraform.io/hashicorp/null",
+			Action:        ActionDelete,
+			OldVersion:    "3.2.1",
+			RemovedHashes: []string{"h1:null="},
+		},
+		{
+			Source:      "registry.terraform.io/integrations/github",
+			Action:      ActionCreate,
+			NewVersion:  "5.26.0",
+			AddedHashes: []string{"h1:github="},
+		},
+	}
+	got := DiffLockFiles(old, new)
+	if !cmp.Equal(got, expected) {
+		t.Errorf("Got: %+v, Expected: %+v", got, expected)
+	}
+}
+
+func TestProvidersLock(t *testing.T) {
+	dir, err := ioutil.TempDir("", "terralib")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, LockFileName)
+	lock, _ := ParseLockFile([]byte(lockFileTest))
+	lock.Providers[1].Hashes = lock.Providers[1].Hashes[:1]
+	if err := lock.Save(path); err != nil {
+		t.Fatal(err)
+	}
+
+	var args []string
+	tf := Terralib{
+		ConfigPath: dir,
+		Executor: executorFunc(func(ctx context.Context, inv Invocation) (Result, error) {
+			args = inv.Args
+			return Result{}, ioutil.WriteFile(path, []byte(lockFileTest), 0644)
+		}),
+	}
+	output, err := tf.ProvidersLock("linux_amd64", "darwin_arm64")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected := []string{"providers", "lock", "-platform=linux_amd64", "-platform=darwin_arm64"}; !cmp.Equal(args, expected) {
+		t.Errorf("Got: %v, Expected: %v", args, expected)
+	}
+	expected := []LockChange{{
+		Source:      "registry.terraform.io/hashicorp/random",
+		Action:      ActionUpdate,
+		OldVersion:  "3.5.1",
+		NewVersion:  "3.5.1",
+		AddedHashes: []string{"zh:04e3fbd610cb52c1017d282531364b9c53ef72b6bc533acb2a90671957324a64"},
+	}}
+	if !cmp.Equal(output.Changes, expected) {
+		t.Errorf("Got: %+v, Expected: %+v", output.Changes, expected)
+	}
+
+	tf.Executor = executorFunc(func(ctx context.Context, inv Invocation) (Result, error) {
+		return Result{Output: []byte(initOutputProvidersTest)}, nil
+	})
+	initOutput, err := tf.Init(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expectedHashes := output.Lock.Provider("hashicorp/random").Hashes
+	if hashes := initOutput.InitializedProviders[1].Hashes; !cmp.Equal(hashes, expectedHashes) {
+		t.Errorf("Got: %v, Expected the hashes of the lock file: %v", hashes, expectedHashes)
+	}
+}
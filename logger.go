@@ -0,0 +1,136 @@
+package terralib
+
+import (
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+)
+
+// LogLevel is the level of a log record
+type LogLevel int
+
+// Log levels, LogInfo being the default of Terralib.LogLevel
+const (
+	LogDebug LogLevel = iota - 1
+	LogInfo
+	LogWarn
+	LogError
+)
+
+const redacted = "<redacted>"
+
+// redactedFlags are the options whose key=value arguments have their value redacted
+var redactedFlags = []string{"-var", "-backend-config"}
+
+func (l LogLevel) String() string {
+	switch l {
+	case LogDebug:
+		return "debug"
+	case LogInfo:
+		return "info"
+	case LogWarn:
+		return "warn"
+	case LogError:
+		return "error"
+	}
+	return fmt.Sprintf("level(%d)", int(l))
+}
+
+// Logger receives a record for each command run by terralib. Fields hold the
+// command, arguments, working directory, terraform version, exit code,
+// duration and error code
+type Logger interface {
+	Log(level LogLevel, msg string, fields map[string]interface{})
+}
+
+// LoggerFunc allows to use a function as a Logger, for example to send the
+// records to a structured logger
+type LoggerFunc func(level LogLevel, msg string, fields map[string]interface{})
+
+// Log calls f(level, msg, fields)
+func (f LoggerFunc) Log(level LogLevel, msg string, fields map[string]interface{}) {
+	f(level, msg, fields)
+}
+
+type stdLogger struct {
+	logger *log.Logger
+}
+
+// StdLogger returns a Logger writing records as text lines to a standard
+// library logger, or to the standard logger if nil
+func StdLogger(logger *log.Logger) Logger {
+	return stdLogger{logger: logger}
+}
+
+func (l stdLogger) Log(level LogLevel, msg string, fields map[string]interface{}) {
+	keys := make([]string, 0, len(fields))
+	for k := range fields {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	var b strings.Builder
+	fmt.Fprintf(&b, "[%s] %s", level, msg)
+	for _, k := range keys {
+		fmt.Fprintf(&b, " %s=%v", k, fields[k])
+	}
+	if l.logger == nil {
+		log.Print(b.String())
+		return
+	}
+	l.logger.Print(b.String())
+}
+
+func (t *Terralib) log(level LogLevel, msg string, fields map[string]interface{}) {
+	if t.Logger == nil || level < t.LogLevel {
+		return
+	}
+	t.Logger.Log(level, msg, fields)
+}
+
+// redactOptions hides the values of variables and backend settings given as
+// options, like -var=password=x or -var followed by password=x
+func redactOptions(options []string) []string {
+	redactedOptions := make([]string, len(options))
+	for i, option := range options {
+		if i > 0 && isRedactedFlag(options[i-1]) {
+			redactedOptions[i] = redactValue(option)
+			continue
+		}
+		redactedOptions[i] = redactOption(option)
+	}
+	return redactedOptions
+}
+
+func redactOption(option string) string {
+	for _, flag := range redactedFlags {
+		if !strings.HasPrefix(option, flag+"=") && !strings.HasPrefix(option, flag+" ") {
+			continue
+		}
+		value := strings.Trim(option[len(flag)+1:], " '\"")
+		if !strings.Contains(value, "=") {
+			return option
+		}
+		return flag + "=" + redactValue(value)
+	}
+	return option
+}
+
+// redactValue hides the value of a name=value setting. Other values, like a
+// backend configuration file, are kept
+func redactValue(setting string) string {
+	i := strings.Index(setting, "=")
+	if i < 0 {
+		return setting
+	}
+	return setting[:i] + "=" + redacted
+}
+
+func isRedactedFlag(option string) bool {
+	for _, flag := range redactedFlags {
+		if option == flag {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,96 @@
+package terralib
+
+import (
+	"bytes"
+	"context"
+	"log"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestRedactOption(t *testing.T) {
+	tests := map[string]string{
+		"-var=password=hunter2":         "-var=password=<redacted>",
+		"-var 'db_password=hunter2'":    "-var=db_password=<redacted>",
+		"-backend-config=access_key=AK": "-backend-config=access_key=<redacted>",
+		"-backend-config=backend.hcl":   "-backend-config=backend.hcl",
+		"-var-file=prod.tfvars":         "-var-file=prod.tfvars",
+		"-out=planfile":                 "-out=planfile",
+	}
+	for option, expected := range tests {
+		if got := redactOption(option); got != expected {
+			t.Errorf("Got: %s, Expected: %s", got, expected)
+		}
+	}
+}
+
+func TestRedactOptions(t *testing.T) {
+	options := []string{"-var", "password=x", "-backend-config", "backend.hcl", "-backend-config", "access_key=AK", "-var=region=eu", "-no-color"}
+	expected := []string{"-var", "password=<redacted>", "-backend-config", "backend.hcl", "-backend-config", "access_key=<redacted>", "-var=region=<redacted>", "-no-color"}
+	if got := redactOptions(options); !cmp.Equal(got, expected) {
+		t.Errorf("Got: %v, Expected: %v", got, expected)
+	}
+}
+
+func TestStdLogger(t *testing.T) {
+	var b bytes.Buffer
+	logger := StdLogger(log.New(&b, "", 0))
+	logger.Log(LogError, "Ran terraform plan", map[string]interface{}{
+		"command":    "plan",
+		"exit_code":  1,
+		"duration":   2 * time.Second,
+		"error_code": ErrPlanDefault,
+	})
+	expected := "[error] Ran terraform plan command=plan duration=2s error_code=errDefault exit_code=1\n"
+	if got := b.String(); got != expected {
+		t.Errorf("Got: %q, Expected: %q", got, expected)
+	}
+}
+
+func TestLogLevel(t *testing.T) {
+	var levels []LogLevel
+	tf := Terralib{
+		Logger: LoggerFunc(func(level LogLevel, msg string, fields map[string]interface{}) {
+			levels = append(levels, level)
+		}),
+		LogLevel: LogWarn,
+	}
+	tf.log(LogDebug, "debug", nil)
+	tf.log(LogInfo, "info", nil)
+	tf.log(LogError, "error", nil)
+	if len(levels) != 1 || levels[0] != LogError {
+		t.Errorf("Got: %v, Expected: [error]", levels)
+	}
+}
+
+func TestLogTerraformVersion(t *testing.T) {
+	var args [][]string
+	var versions []interface{}
+	tf := Terralib{
+		Logger: LoggerFunc(func(level LogLevel, msg string, fields map[string]interface{}) {
+			versions = append(versions, fields["terraform_version"])
+		}),
+		Executor: executorFunc(func(ctx context.Context, inv Invocation) (Result, error) {
+			args = append(args, inv.Args)
+			if inv.Args[0] == "version" {
+				return Result{Output: []byte("Terraform v1.5.7\non linux_amd64\n")}, nil
+			}
+			return Result{}, nil
+		}),
+	}
+	// No version is detected behind the caller's back
+	tf.Validate(nil)
+	if len(args) != 1 || len(versions) != 1 || versions[0] != nil {
+		t.Errorf("Got commands %v and versions %v, Expected only validate without version", args, versions)
+	}
+	version, err := tf.DetectVersion()
+	if err != nil || version != "1.5.7" || tf.TerraformVersion != "1.5.7" {
+		t.Errorf("Got: %q, %v, Expected: 1.5.7", version, err)
+	}
+	tf.Validate(nil)
+	if len(versions) != 2 || versions[1] != "1.5.7" {
+		t.Errorf("Got versions: %v, Expected the detected version to be logged", versions)
+	}
+}
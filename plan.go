@@ -1,78 +1,188 @@
 package terralib
 
 import (
-	"os/exec"
-	"regexp"
-	"strings"
+	"fmt"
+	"io/ioutil"
+	"os"
 )
 
 // Exported error codes
 const (
 	ErrInvalidResourceType               string = "errInvalidResourceType"
 	ErrCouldNotSatisfyPluginRequirements string = "errCouldNotSatisfyPluginRequirements"
-	ErrPlanDefault                       string = "errPlanDefault"
+	// ErrPlanDefault is the code of the errors of plan no classifier knows
+	ErrPlanDefault                     string = "errDefault"
+	ErrUnsupportedArgument             string = "errUnsupportedArgument"
+	ErrMissingRequiredArgument         string = "errMissingRequiredArgument"
+	ErrUndeclaredResourceReference     string = "errUndeclaredResourceReference"
+	ErrUndeclaredVariableReference     string = "errUndeclaredVariableReference"
+	ErrUndeclaredModuleReference       string = "errUndeclaredModuleReference"
+	ErrInvalidFunctionCall             string = "errInvalidFunctionCall"
+	ErrCycle                           string = "errCycle"
+	ErrInvalidCountArgument            string = "errInvalidCountArgument"
+	ErrInvalidForEachArgument          string = "errInvalidForEachArgument"
+	ErrUnknownForEachValues            string = "errUnknownForEachValues"
+	ErrProviderConfigurationNotPresent string = "errProviderConfigurationNotPresent"
+	ErrModuleNotInstalled              string = "errModuleNotInstalled"
 )
 
-var planErrors = map[string]string{
-	ErrInvalidResourceType: ("The provider (.*) does not support resource type\n" +
-		"\"(.*)\"."),
-	ErrCouldNotSatisfyPluginRequirements: ("provider.(.*): no suitable version installed\n" +
-		"  version requirements: \"(.*)\"\n" +
-		"  versions installed: (.*)"),
+// configurationErrors are the codes of errors in the configuration, which
+// refer to a file, line and address
+var configurationErrors = map[string]bool{
+	ErrUnsupportedArgument:             true,
+	ErrMissingRequiredArgument:         true,
+	ErrUndeclaredResourceReference:     true,
+	ErrUndeclaredVariableReference:     true,
+	ErrUndeclaredModuleReference:       true,
+	ErrInvalidFunctionCall:             true,
+	ErrCycle:                           true,
+	ErrInvalidCountArgument:            true,
+	ErrInvalidForEachArgument:          true,
+	ErrUnknownForEachValues:            true,
+	ErrProviderConfigurationNotPresent: true,
+	ErrModuleNotInstalled:              true,
 }
 
-// PlanError represents an error on the Init command
+var planClassifiers = []Classifier{
+	NewClassifier(ErrInvalidResourceType, "The provider (.*) does not support resource type\\s+"+
+		"\"(.*)\".", CommandPlan),
+	NewClassifier(ErrCouldNotSatisfyPluginRequirements, "provider.(.*): no suitable version installed\n"+
+		"  version requirements: \"(.*)\"\n"+
+		"  versions installed: (.*)", CommandPlan),
+	NewClassifier(ErrUnsupportedArgument, `Error: (?P<reason>Unsupported (?:argument|block type))`,
+		CommandPlan, CommandApply, CommandDestroy),
+	NewClassifier(ErrMissingRequiredArgument, `Error: (?P<reason>Missing required argument)`,
+		CommandPlan, CommandApply, CommandDestroy),
+	NewClassifier(ErrUndeclaredResourceReference, `Error: (?P<reason>Reference to undeclared resource)`,
+		CommandPlan, CommandApply, CommandDestroy),
+	NewClassifier(ErrUndeclaredVariableReference, `Error: (?P<reason>Reference to undeclared input variable)`,
+		CommandPlan, CommandApply, CommandDestroy),
+	NewClassifier(ErrUndeclaredModuleReference, `Error: (?P<reason>Reference to undeclared module)`,
+		CommandPlan, CommandApply, CommandDestroy),
+	NewClassifier(ErrInvalidFunctionCall, `Error: (?P<reason>Call to unknown function|Invalid function argument|`+
+		`Error in function call|(?:Not enough|Too many) function arguments)`,
+		CommandPlan, CommandApply, CommandDestroy),
+	NewClassifier(ErrCycle, `Error: (?P<reason>Cycle: [^\n]*)`,
+		CommandPlan, CommandApply, CommandDestroy),
+	NewClassifier(ErrInvalidCountArgument, `Error: (?P<reason>Invalid count argument)`,
+		CommandPlan, CommandApply, CommandDestroy),
+	// Unknown values are reported as an invalid for_each argument, so they
+	// are told apart before it, within the same diagnostic
+	NewClassifier(ErrUnknownForEachValues, `Error: (?P<reason>Invalid for_each argument)\n`+diagnosticLines+
+		`[^\n]*(?:cannot\s+be\s+determined\s+until\s+apply|known\s+only\s+after\s+apply)`,
+		CommandPlan, CommandApply, CommandDestroy),
+	NewClassifier(ErrInvalidForEachArgument, `Error: (?P<reason>Invalid for_each argument)`,
+		CommandPlan, CommandApply, CommandDestroy),
+	NewClassifier(ErrProviderConfigurationNotPresent, `Error: (?P<reason>Provider configuration not present)`,
+		CommandPlan, CommandApply, CommandDestroy),
+	NewClassifier(ErrModuleNotInstalled, `Error: (?P<reason>Module not installed)`,
+		CommandPlan, CommandApply, CommandDestroy),
+	defaultClassifier(ErrPlanDefault, CommandPlan),
+}
+
+// PlanError represents an error on the Plan command. Lock is set when the
+// state is locked by another process, Violations when the plan breaks a policy,
+// Variable when the error refers to an input variable, File, Line and Address
+// when it refers to the configuration, and Cycle on ErrCycle
 type PlanError struct {
-	Reason string
-	Code   string
+	Reason     string
+	Code       string
+	Lock       *LockInfo
+	Violations []Violation
+	Variable   string
+	File       string
+	Line       int
+	Address    string
+	Cycle      *CycleError
 }
 
 // PlanOutput represents the output of the plan command
 type PlanOutput struct {
-	Raw string
+	Raw      string
+	Attempts []Attempt
+	// Plan and Violations are set when Terralib.Policies is set
+	Plan       *ShowOutput
+	Violations []Violation
 }
 
 func (e PlanError) Error() string {
 	return e.Code
 }
 
-// Plan executes the 'terraform plan' command
-func (t *Terralib) Plan(options []string) (PlanOutput, error) {
-
-	cmdString := formatCommand("plan", options)
-	cmd := exec.Command("sh", "-c", cmdString)
-	cmd.Dir = t.ConfigPath
-	stdOutputError, _ := cmd.CombinedOutput()
-	planError := findPlanError(stdOutputError)
-	return PlanOutput{
-		Raw: string(stdOutputError),
-	}, planError
+// Plan executes the 'terraform plan' command. When Terralib.Policies is set,
+// the plan is evaluated against them, saving it to a temporary file if no
+// -out option is given
+func (t *Terralib) Plan(options []string) (planOutput PlanOutput, err error) {
+	options = t.inputOptions(options)
+	if err := t.beforeCommand(CommandPlan, options); err != nil {
+		return PlanOutput{}, err
+	}
+	defer func() {
+		t.afterCommand(CommandPlan, options, planOutput, err)
+	}()
+	planFile := planFileOption(options)
+	if t.Policies != nil && planFile == "" {
+		f, err := ioutil.TempFile("", "terralib-*.tfplan")
+		if err != nil {
+			return PlanOutput{}, err
+		}
+		f.Close()
+		defer os.Remove(f.Name())
+		planFile = f.Name()
+		options = append(options, "-out="+planFile)
+	}
+	varOptions, env, cleanup, err := t.variableOptions()
+	defer cleanup()
+	if err != nil {
+		return PlanOutput{}, err
+	}
+	stdOutputError, match, attempts := t.execute(CommandPlan, append(varOptions, options...), env)
+	planOutput = PlanOutput{
+		Raw:      string(stdOutputError),
+		Attempts: attempts,
+	}
+	if match != nil || t.Policies == nil {
+		return planOutput, planErrorFrom(match, stdOutputError)
+	}
+	plan, violations, err := t.evaluatePolicies(planFile)
+	if err != nil {
+		return planOutput, err
+	}
+	planOutput.Plan = plan
+	planOutput.Violations = violations
+	if blocking := Blocking(violations); len(blocking) > 0 {
+		return planOutput, PlanError{
+			Reason:     fmt.Sprintf("Plan breaks %d blocking policies", len(blocking)),
+			Code:       ErrPolicyViolation,
+			Violations: blocking,
+		}
+	}
+	return planOutput, nil
 }
 
 func findPlanError(output []byte) error {
-	var planError PlanError
-	for k, v := range planErrors {
-		r := regexp.MustCompile(v)
-		line := r.Find(output)
-		if line != nil {
-			planError = PlanError{
-				Reason: string(line),
-				Code:   k,
-			}
-			return planError
-		}
+	return planErrorFrom(DefaultClassifiers.Classify(CommandPlan, "", output), output)
+}
+
+func planErrorFrom(match *Match, output []byte) error {
+	if match == nil {
+		return nil
 	}
-	// Get a default error or else return no error
-	r := regexp.MustCompile("Error: (.*).")
-	line := r.Find(output)
-	if line != nil {
-		reason := strings.Split(string(line), "Error: ")
-		if reason != nil {
-			return PlanError{
-				Reason: strings.TrimSuffix(reason[1], "."),
-				Code:   ErrPlanDefault,
-			}
-		}
+	output = unframeDiagnostics(output)
+	block := diagnosticBlock(output, match.Reason)
+	planError := PlanError{
+		Reason: match.Reason,
+		Code:   match.Code,
+		Lock:   lockInfoFrom(match, output),
+		Cycle:  cycleFrom(match),
+	}
+	if isVariableError(match.Code) {
+		planError.Variable = diagnosticVariable(block)
+		planError.File, planError.Line = diagnosticLocation(block)
+	}
+	if configurationErrors[match.Code] {
+		planError.File, planError.Line = diagnosticLocation(block)
+		planError.Address = diagnosticAddress(block)
 	}
-	return nil
+	return planError
 }
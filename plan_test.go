@@ -76,3 +76,58 @@ func TestFindErrCouldNotSatisfyPluginRequirements(t *testing.T) {
 		t.Errorf("Got: %+v, Expected: %+v", got, expected)
 	}
 }
+
+const planOutputUnsupportedArgumentInModuleTest string = `
+Error: Unsupported argument
+
+  on main.tf line 13, in module "vpc":
+  13:   cidr_blok = "10.0.0.0/16"
+
+An argument named "cidr_blok" is not expected here.
+`
+
+func TestFindErrUnsupportedArgumentInModule(t *testing.T) {
+	expected := PlanError{
+		Reason:  "Unsupported argument",
+		Code:    ErrUnsupportedArgument,
+		File:    "main.tf",
+		Line:    13,
+		Address: "module.vpc",
+	}
+	got := findPlanError([]byte(planOutputUnsupportedArgumentInModuleTest))
+	if !cmp.Equal(got, expected) {
+		t.Errorf("Got: %+v, Expected: %+v", got, expected)
+	}
+}
+
+const planOutputInvalidForEachThenUnknownTest string = `
+Error: Invalid for_each argument
+
+  on main.tf line 12, in resource "aws_instance" "web":
+  12:   for_each = var.names
+
+The given "for_each" argument value is unsuitable: the "for_each" argument
+must be a map, or set of strings, and you have provided a value of type list
+of string.
+
+Error: Invalid index
+
+  on main.tf line 40, in output "zone":
+  40:   value = aws_route53_zone.main[0].zone_id
+
+The index of aws_route53_zone.main will be known only after apply.
+`
+
+func TestFindErrInvalidForEachBeforeUnknownValues(t *testing.T) {
+	expected := PlanError{
+		Reason:  "Invalid for_each argument",
+		Code:    ErrInvalidForEachArgument,
+		File:    "main.tf",
+		Line:    12,
+		Address: "aws_instance.web",
+	}
+	got := findPlanError([]byte(planOutputInvalidForEachThenUnknownTest))
+	if !cmp.Equal(got, expected) {
+		t.Errorf("Got: %+v, Expected: %+v", got, expected)
+	}
+}
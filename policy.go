@@ -0,0 +1,384 @@
+package terralib
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"strings"
+)
+
+// Exported error codes
+const (
+	ErrPolicyViolation        string = "errPolicyViolation"
+	ErrPolicyPlanFileRequired string = "errPolicyPlanFileRequired"
+	ErrPolicyPlanUnreadable   string = "errPolicyPlanUnreadable"
+)
+
+// Severity of a policy violation
+type Severity string
+
+// Severities, only SeverityError blocks an apply
+const (
+	SeverityInfo    Severity = "info"
+	SeverityWarning Severity = "warning"
+	SeverityError   Severity = "error"
+)
+
+// Kinds of rules in policy files
+const (
+	RuleDenyDelete       string = "deny_delete"
+	RuleRequireTags      string = "require_tags"
+	RuleForbidPublicCIDR string = "forbid_public_cidr"
+	RuleMaxChanges       string = "max_changes"
+)
+
+var publicCIDRs = []string{"0.0.0.0/0", "::/0"}
+
+// Violation represents a rule broken by a plan
+type Violation struct {
+	Rule     string
+	Severity Severity
+	Address  string
+	Message  string
+}
+
+// Rule represents a check over a plan. Violations returned by Check with no
+// rule name or severity get the ones of the rule
+type Rule struct {
+	Name     string
+	Severity Severity
+	Check    func(plan *ShowOutput) []Violation
+}
+
+// PolicySet represents a set of rules evaluated against plans
+type PolicySet struct {
+	Rules []Rule
+}
+
+// RuleConfig represents a rule in a policy file
+type RuleConfig struct {
+	Name          string   `json:"name"`
+	Kind          string   `json:"kind"`
+	Severity      Severity `json:"severity,omitempty"`
+	ResourceTypes []string `json:"resource_types,omitempty"`
+	Tags          []string `json:"tags,omitempty"`
+	Attributes    []string `json:"attributes,omitempty"`
+	Max           int      `json:"max,omitempty"`
+}
+
+// PolicyFile represents the content of a JSON policy file
+type PolicyFile struct {
+	Rules []RuleConfig `json:"rules"`
+}
+
+// NewPolicySet returns a policy set with the given rules
+func NewPolicySet(rules ...Rule) *PolicySet {
+	return &PolicySet{Rules: rules}
+}
+
+// LoadPolicyFile reads a policy set from a JSON policy file
+func LoadPolicyFile(path string) (*PolicySet, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return ParsePolicyFile(data)
+}
+
+// ParsePolicyFile reads a policy set from the content of a JSON policy file
+func ParsePolicyFile(data []byte) (*PolicySet, error) {
+	var file PolicyFile
+	if err := json.Unmarshal(data, &file); err != nil {
+		return nil, err
+	}
+	policies := NewPolicySet()
+	for _, config := range file.Rules {
+		rule, err := config.Rule()
+		if err != nil {
+			return nil, err
+		}
+		policies.Rules = append(policies.Rules, rule)
+	}
+	return policies, nil
+}
+
+// Rule returns the rule described by the configuration
+func (c RuleConfig) Rule() (Rule, error) {
+	var rule Rule
+	switch c.Kind {
+	case RuleDenyDelete:
+		rule = DenyDelete(c.ResourceTypes...)
+	case RuleRequireTags:
+		rule = RequireTags(c.ResourceTypes, c.Tags...)
+	case RuleForbidPublicCIDR:
+		rule = ForbidPublicCIDR(c.ResourceTypes, c.Attributes...)
+	case RuleMaxChanges:
+		rule = MaxChanges(c.Max)
+	default:
+		return rule, errors.New("Unknown rule kind \"" + c.Kind + "\"")
+	}
+	if c.Name != "" {
+		rule.Name = c.Name
+	}
+	if c.Severity != "" {
+		rule.Severity = c.Severity
+	}
+	return rule, nil
+}
+
+// Evaluate runs every rule against the plan and returns the violations found
+func (p *PolicySet) Evaluate(plan *ShowOutput) []Violation {
+	var violations []Violation
+	for _, rule := range p.Rules {
+		for _, v := range rule.Check(plan) {
+			if v.Rule == "" {
+				v.Rule = rule.Name
+			}
+			if v.Severity == "" {
+				v.Severity = rule.Severity
+			}
+			violations = append(violations, v)
+		}
+	}
+	return violations
+}
+
+// Blocking returns the violations with SeverityError
+func Blocking(violations []Violation) []Violation {
+	var blocking []Violation
+	for _, v := range violations {
+		if v.Severity == SeverityError {
+			blocking = append(blocking, v)
+		}
+	}
+	return blocking
+}
+
+// DenyDelete returns a rule forbidding to delete or replace resources of the
+// given types, or of any type if none is given
+func DenyDelete(resourceTypes ...string) Rule {
+	return Rule{
+		Name:     RuleDenyDelete,
+		Severity: SeverityError,
+		Check: func(plan *ShowOutput) []Violation {
+			var violations []Violation
+			for _, rc := range plan.Changes {
+				action := rc.Change.Action()
+				if (action != ActionDelete && action != ActionReplace) || !matchesType(rc.Type, resourceTypes) {
+					continue
+				}
+				violations = append(violations, Violation{
+					Address: rc.Address,
+					Message: fmt.Sprintf("%s would be %sd", rc.Address, action),
+				})
+			}
+			return violations
+		},
+	}
+}
+
+// RequireTags returns a rule requiring the given tags on the planned values of
+// resources of the given types that support tags
+func RequireTags(resourceTypes []string, tags ...string) Rule {
+	return Rule{
+		Name:     RuleRequireTags,
+		Severity: SeverityError,
+		Check: func(plan *ShowOutput) []Violation {
+			var violations []Violation
+			for _, r := range plan.Planned.Resources() {
+				value, ok := r.Values["tags"]
+				if r.Mode != "managed" || !ok || !matchesType(r.Type, resourceTypes) {
+					continue
+				}
+				present, _ := value.(map[string]interface{})
+				var missing []string
+				for _, tag := range tags {
+					if _, ok := present[tag]; !ok {
+						missing = append(missing, tag)
+					}
+				}
+				if len(missing) > 0 {
+					violations = append(violations, Violation{
+						Address: r.Address,
+						Message: fmt.Sprintf("%s is missing tags: %s", r.Address, strings.Join(missing, ", ")),
+					})
+				}
+			}
+			return violations
+		},
+	}
+}
+
+// ForbidPublicCIDR returns a rule forbidding public CIDR blocks in the given
+// attributes, at any depth, of resources of the given types
+func ForbidPublicCIDR(resourceTypes []string, attributes ...string) Rule {
+	return Rule{
+		Name:     RuleForbidPublicCIDR,
+		Severity: SeverityError,
+		Check: func(plan *ShowOutput) []Violation {
+			var violations []Violation
+			for _, rc := range plan.Changes {
+				if !matchesType(rc.Type, resourceTypes) {
+					continue
+				}
+				for _, attribute := range attributes {
+					if cidr := findPublicCIDR(rc.Change.After, attribute); cidr != "" {
+						violations = append(violations, Violation{
+							Address: rc.Address,
+							Message: fmt.Sprintf("%s allows %s in %s", rc.Address, cidr, attribute),
+						})
+					}
+				}
+			}
+			return violations
+		},
+	}
+}
+
+// MaxChanges returns a rule limiting the number of resources changed by a plan
+func MaxChanges(max int) Rule {
+	return Rule{
+		Name:     RuleMaxChanges,
+		Severity: SeverityError,
+		Check: func(plan *ShowOutput) []Violation {
+			changes := 0
+			for _, rc := range plan.Changes {
+				if action := rc.Change.Action(); action != ActionNoOp && action != ActionRead {
+					changes++
+				}
+			}
+			if changes <= max {
+				return nil
+			}
+			return []Violation{{
+				Message: fmt.Sprintf("Plan changes %d resources, the maximum is %d", changes, max),
+			}}
+		},
+	}
+}
+
+func matchesType(resourceType string, resourceTypes []string) bool {
+	if len(resourceTypes) == 0 {
+		return true
+	}
+	for _, t := range resourceTypes {
+		if t == resourceType {
+			return true
+		}
+	}
+	return false
+}
+
+// findPublicCIDR returns the first public CIDR found in the values of the
+// attribute, looking for it in nested blocks too
+func findPublicCIDR(value interface{}, attribute string) string {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		for k, nested := range v {
+			if k == attribute {
+				if cidr := publicCIDRIn(nested); cidr != "" {
+					return cidr
+				}
+			}
+			if cidr := findPublicCIDR(nested, attribute); cidr != "" {
+				return cidr
+			}
+		}
+	case []interface{}:
+		for _, nested := range v {
+			if cidr := findPublicCIDR(nested, attribute); cidr != "" {
+				return cidr
+			}
+		}
+	}
+	return ""
+}
+
+func publicCIDRIn(value interface{}) string {
+	switch v := value.(type) {
+	case string:
+		for _, cidr := range publicCIDRs {
+			if v == cidr {
+				return cidr
+			}
+		}
+	case []interface{}:
+		for _, nested := range v {
+			if cidr := publicCIDRIn(nested); cidr != "" {
+				return cidr
+			}
+		}
+	}
+	return ""
+}
+
+// planFileOption returns the plan file given to plan in a -out option
+func planFileOption(options []string) string {
+	planFile, _ := splitOutOption(options)
+	return planFile
+}
+
+// valueFlags are the options of plan and apply taking a value, which may be
+// given as the next argument rather than after "="
+var valueFlags = map[string]bool{
+	"-var":          true,
+	"-var-file":     true,
+	"-target":       true,
+	"-replace":      true,
+	"-state":        true,
+	"-state-out":    true,
+	"-backup":       true,
+	"-lock-timeout": true,
+	"-parallelism":  true,
+	"-out":          true,
+}
+
+// applyPlanFile returns the plan file given to apply, its last argument that
+// is neither an option nor the value of one
+func applyPlanFile(options []string) string {
+	var planFile string
+	for i := 0; i < len(options); i++ {
+		switch {
+		case valueFlags[options[i]]:
+			i++
+		case strings.HasPrefix(options[i], "-"):
+		default:
+			planFile = options[i]
+		}
+	}
+	return planFile
+}
+
+// evaluatePolicies shows a plan file and evaluates the policies against it
+func (t *Terralib) evaluatePolicies(planFile string) (*ShowOutput, []Violation, error) {
+	_, stdout, err := t.show(planFile)
+	if err != nil {
+		return nil, nil, err
+	}
+	plan, err := decodePlan(stdout)
+	if err != nil {
+		return nil, nil, err
+	}
+	return plan, t.Policies.Evaluate(plan), nil
+}
+
+// decodePlan reads the JSON plan printed by show. It fails with
+// ErrPolicyPlanUnreadable rather than returning an empty plan, which would
+// break no policy
+func decodePlan(stdout []byte) (*ShowOutput, error) {
+	var plan ShowOutput
+	if err := json.Unmarshal(stdout, &plan); err != nil {
+		return nil, ShowError{
+			Reason: "The plan could not be read: " + err.Error(),
+			Code:   ErrPolicyPlanUnreadable,
+		}
+	}
+	if plan.FormatVersion == "" {
+		return nil, ShowError{
+			Reason: "The plan could not be read: it has no format version",
+			Code:   ErrPolicyPlanUnreadable,
+		}
+	}
+	return &plan, nil
+}
@@ -0,0 +1,313 @@
+package terralib
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+const showOutputPlanTest string = `{
+  "format_version": "1.2",
+  "terraform_version": "1.5.7",
+  "planned_values": {
+    "root_module": {
+      "resources": [
+        {
+          "address": "aws_s3_bucket.logs",
+          "mode": "managed",
+          "type": "aws_s3_bucket",
+          "name": "logs",
+          "provider_name": "registry.terraform.io/hashicorp/aws",
+          "schema_version": 0,
+          "values": {"bucket": "example-logs", "tags": {"owner": "platform"}}
+        },
+        {
+          "address": "aws_security_group.web",
+          "mode": "managed",
+          "type": "aws_security_group",
+          "name": "web",
+          "provider_name": "registry.terraform.io/hashicorp/aws",
+          "schema_version": 1,
+          "values": {"name": "web", "tags": null}
+        }
+      ],
+      "child_modules": [
+        {
+          "address": "module.net",
+          "resources": [
+            {
+              "address": "module.net.aws_vpc.main",
+              "mode": "managed",
+              "type": "aws_vpc",
+              "name": "main",
+              "provider_name": "registry.terraform.io/hashicorp/aws",
+              "schema_version": 1,
+              "values": {"cidr_block": "10.0.0.0/16", "tags": {"owner": "network", "env": "prod"}}
+            }
+          ]
+        }
+      ]
+    }
+  },
+  "resource_changes": [
+    {
+      "address": "aws_db_instance.main",
+      "mode": "managed",
+      "type": "aws_db_instance",
+      "name": "main",
+      "provider_name": "registry.terraform.io/hashicorp/aws",
+      "change": {"actions": ["delete"], "before": {"identifier": "main"}, "after": null}
+    },
+    {
+      "address": "aws_s3_bucket.logs",
+      "mode": "managed",
+      "type": "aws_s3_bucket",
+      "name": "logs",
+      "provider_name": "registry.terraform.io/hashicorp/aws",
+      "change": {"actions": ["no-op"], "before": {"bucket": "example-logs"}, "after": {"bucket": "example-logs"}}
+    },
+    {
+      "address": "aws_security_group.web",
+      "mode": "managed",
+      "type": "aws_security_group",
+      "name": "web",
+      "provider_name": "registry.terraform.io/hashicorp/aws",
+      "change": {
+        "actions": ["create"],
+        "before": null,
+        "after": {"name": "web", "ingress": [{"from_port": 443, "cidr_blocks": ["0.0.0.0/0"]}]}
+      }
+    },
+    {
+      "address": "module.net.aws_vpc.main",
+      "module_address": "module.net",
+      "mode": "managed",
+      "type": "aws_vpc",
+      "name": "main",
+      "provider_name": "registry.terraform.io/hashicorp/aws",
+      "change": {"actions": ["delete", "create"], "before": {"cidr_block": "10.1.0.0/16"}, "after": {"cidr_block": "10.0.0.0/16"}}
+    }
+  ]
+}`
+
+const policyFileTest string = `{
+  "rules": [
+    {"name": "keep-databases", "kind": "deny_delete", "resource_types": ["aws_db_instance"]},
+    {"kind": "require_tags", "tags": ["owner"], "severity": "warning"},
+    {"kind": "forbid_public_cidr", "resource_types": ["aws_security_group"], "attributes": ["cidr_blocks"]},
+    {"kind": "max_changes", "max": 2, "severity": "info"}
+  ]
+}`
+
+func TestEvaluatePolicyFile(t *testing.T) {
+	var plan ShowOutput
+	if err := json.Unmarshal([]byte(showOutputPlanTest), &plan); err != nil {
+		t.Fatal(err)
+	}
+	policies, err := ParsePolicyFile([]byte(policyFileTest))
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []Violation{
+		{
+			Rule:     "keep-databases",
+			Severity: SeverityError,
+			Address:  "aws_db_instance.main",
+			Message:  "aws_db_instance.main would be deleted",
+		},
+		{
+			Rule:     RuleRequireTags,
+			Severity: SeverityWarning,
+			Address:  "aws_security_group.web",
+			Message:  "aws_security_group.web is missing tags: owner",
+		},
+		{
+			Rule:     RuleForbidPublicCIDR,
+			Severity: SeverityError,
+			Address:  "aws_security_group.web",
+			Message:  "aws_security_group.web allows 0.0.0.0/0 in cidr_blocks",
+		},
+		{
+			Rule:     RuleMaxChanges,
+			Severity: SeverityInfo,
+			Message:  "Plan changes 3 resources, the maximum is 2",
+		},
+	}
+	got := policies.Evaluate(&plan)
+	if !cmp.Equal(got, expected) {
+		t.Errorf("Got: %+v, Expected: %+v", got, expected)
+	}
+	if blocking := Blocking(got); len(blocking) != 2 {
+		t.Errorf("Got %d blocking violations, Expected: 2", len(blocking))
+	}
+}
+
+func TestDenyDeleteReplace(t *testing.T) {
+	var plan ShowOutput
+	if err := json.Unmarshal([]byte(showOutputPlanTest), &plan); err != nil {
+		t.Fatal(err)
+	}
+	expected := []Violation{{
+		Rule:     RuleDenyDelete,
+		Severity: SeverityError,
+		Address:  "module.net.aws_vpc.main",
+		Message:  "module.net.aws_vpc.main would be replaced",
+	}}
+	got := NewPolicySet(DenyDelete("aws_vpc")).Evaluate(&plan)
+	if !cmp.Equal(got, expected) {
+		t.Errorf("Got: %+v, Expected: %+v", got, expected)
+	}
+}
+
+func TestParsePolicyFileUnknownKind(t *testing.T) {
+	_, err := ParsePolicyFile([]byte(`{"rules": [{"kind": "deny_everything"}]}`))
+	if err == nil {
+		t.Errorf("Expected an error for an unknown rule kind")
+	}
+}
+
+func TestApplyPlanFile(t *testing.T) {
+	tests := []struct {
+		options  []string
+		expected string
+	}{
+		{[]string{"-auto-approve", "planfile"}, "planfile"},
+		{[]string{"-auto-approve"}, ""},
+		{[]string{"-target", "aws_instance.x"}, ""},
+		{[]string{"-var", "a=b"}, ""},
+		{[]string{"-var", "a=b", "planfile"}, "planfile"},
+		{[]string{"-target=aws_instance.x", "planfile"}, "planfile"},
+	}
+	for _, test := range tests {
+		if got := applyPlanFile(test.options); got != test.expected {
+			t.Errorf("Got: %q, Expected: %q for %v", got, test.expected, test.options)
+		}
+	}
+}
+
+func TestPlanFileOption(t *testing.T) {
+	tests := []struct {
+		options  []string
+		expected string
+	}{
+		{[]string{"-out=mine.tfplan"}, "mine.tfplan"},
+		{[]string{"-out", "mine.tfplan", "-lock=false"}, "mine.tfplan"},
+		{[]string{"-out", "first.tfplan", "-out=last.tfplan"}, "last.tfplan"},
+		{[]string{"-lock=false"}, ""},
+	}
+	for _, test := range tests {
+		if got := planFileOption(test.options); got != test.expected {
+			t.Errorf("Got: %q, Expected: %q for %v", got, test.expected, test.options)
+		}
+	}
+}
+
+// policyGate returns a Terralib with a policy denying the deletion of
+// databases, whose show command prints stdout and stderr
+func policyGate(stdout string, stderr string) Terralib {
+	return Terralib{
+		Policies: NewPolicySet(DenyDelete("aws_db_instance")),
+		Executor: executorFunc(func(ctx context.Context, inv Invocation) (Result, error) {
+			if inv.Args[0] != CommandShow {
+				return Result{Output: []byte("Apply complete!")}, nil
+			}
+			return Result{
+				Output: []byte(stderr + stdout),
+				Stdout: []byte(stdout),
+				Stderr: []byte(stderr),
+			}, nil
+		}),
+	}
+}
+
+func TestPolicyGateStderr(t *testing.T) {
+	tf := policyGate(showOutputPlanTest, "Warning: the plan was created by a newer version\n")
+	_, err := tf.Apply([]string{"planfile"})
+	if applyErr, ok := err.(ApplyError); !ok || applyErr.Code != ErrPolicyViolation {
+		t.Errorf("Got: %v, Expected: %s", err, ErrPolicyViolation)
+	}
+}
+
+func TestPolicyGateUnreadablePlan(t *testing.T) {
+	tests := []string{
+		"",
+		"Terraform will perform the following actions:",
+		"{}",
+	}
+	for _, stdout := range tests {
+		tf := policyGate(stdout, "")
+		_, err := tf.Apply([]string{"planfile"})
+		if showErr, ok := err.(ShowError); !ok || showErr.Code != ErrPolicyPlanUnreadable {
+			t.Errorf("%q: Got: %v, Expected: %s", stdout, err, ErrPolicyPlanUnreadable)
+		}
+		output, err := tf.Plan(nil)
+		if showErr, ok := err.(ShowError); !ok || showErr.Code != ErrPolicyPlanUnreadable {
+			t.Errorf("%q: Got: %v, Expected: %s", stdout, err, ErrPolicyPlanUnreadable)
+		}
+		if output.Plan != nil || output.Violations != nil {
+			t.Errorf("%q: Got plan: %+v, violations: %+v", stdout, output.Plan, output.Violations)
+		}
+	}
+}
+
+func TestPolicyGateDestroy(t *testing.T) {
+	tf := policyGate(showOutputPlanTest, "")
+	executed := false
+	executor := tf.Executor
+	tf.Executor = executorFunc(func(ctx context.Context, inv Invocation) (Result, error) {
+		executed = true
+		return executor.Execute(ctx, inv)
+	})
+	_, err := tf.Destroy([]string{"-auto-approve"})
+	if applyErr, ok := err.(ApplyError); !ok || applyErr.Code != ErrPolicyPlanFileRequired {
+		t.Errorf("Got: %v, Expected: %s", err, ErrPolicyPlanFileRequired)
+	}
+	if executed {
+		t.Errorf("Destroy ran terraform")
+	}
+}
+
+func TestPolicyGateFlagValues(t *testing.T) {
+	tests := [][]string{
+		{"-auto-approve", "-target", "aws_instance.x"},
+		{"-auto-approve", "-var", "a=b"},
+	}
+	for _, options := range tests {
+		tf := policyGate(showOutputPlanTest, "")
+		var calls [][]string
+		executor := tf.Executor
+		tf.Executor = executorFunc(func(ctx context.Context, inv Invocation) (Result, error) {
+			calls = append(calls, inv.Args)
+			return executor.Execute(ctx, inv)
+		})
+		_, err := tf.Apply(options)
+		if applyErr, ok := err.(ApplyError); !ok || applyErr.Code != ErrPolicyPlanFileRequired {
+			t.Errorf("%v: Got: %v, Expected: %s", options, err, ErrPolicyPlanFileRequired)
+		}
+		if len(calls) > 0 {
+			t.Errorf("%v: Got calls: %v, Expected none", options, calls)
+		}
+	}
+}
+
+func TestPolicyGatePlanOut(t *testing.T) {
+	tf := policyGate(showOutputPlanTest, "")
+	var calls [][]string
+	executor := tf.Executor
+	tf.Executor = executorFunc(func(ctx context.Context, inv Invocation) (Result, error) {
+		calls = append(calls, inv.Args)
+		return executor.Execute(ctx, inv)
+	})
+	if _, err := tf.Plan([]string{"-out", "mine.tfplan"}); err == nil {
+		t.Fatalf("Expected the plan to break the policy")
+	}
+	expected := [][]string{
+		{CommandPlan, "-input=false", "-out", "mine.tfplan"},
+		{CommandShow, "-no-color", "-json", "mine.tfplan"},
+	}
+	if !cmp.Equal(calls, expected) {
+		t.Errorf("Got: %v, Expected: %v", calls, expected)
+	}
+}
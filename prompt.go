@@ -0,0 +1,162 @@
+package terralib
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"strings"
+	"sync"
+)
+
+// Exported error codes
+const (
+	ErrInteractivePrompt string = "errInteractivePrompt"
+)
+
+// promptSuffix ends every question terraform asks
+const promptSuffix = "Enter a value:"
+
+// Prompt represents a question asked by terraform
+type Prompt struct {
+	// Text is the question, like "Do you want to perform these actions?"
+	// followed by its description
+	Text string
+	// Variable is the input variable asked for, if any
+	Variable string
+}
+
+// Prompter answers the questions asked by terraform. An error leaves the
+// question unanswered, failing the command with ErrInteractivePrompt
+type Prompter interface {
+	Prompt(prompt Prompt) (string, error)
+}
+
+// PrompterFunc adapts a function to the Prompter interface
+type PrompterFunc func(prompt Prompt) (string, error)
+
+// Prompt calls f
+func (f PrompterFunc) Prompt(prompt Prompt) (string, error) {
+	return f(prompt)
+}
+
+// promptError is returned by run when terraform asked a question that was
+// not answered
+type promptError struct {
+	prompt Prompt
+}
+
+func (e promptError) Error() string {
+	return "Terraform is asking for input: " + e.prompt.Text
+}
+
+// promptWatcher reads the output of a command as it is written, answering
+// the questions of terraform through its stdin or else cancelling the command
+type promptWatcher struct {
+	prompter Prompter
+	stdin    *answers
+	cancel   context.CancelFunc
+
+	mu         sync.Mutex
+	output     bytes.Buffer
+	checked    int
+	unanswered *Prompt
+}
+
+func (w *promptWatcher) Write(p []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.output.Write(p)
+	if w.unanswered != nil {
+		return len(p), nil
+	}
+	output := bytes.TrimRight(w.output.Bytes(), " ")
+	if len(output) <= w.checked || !bytes.HasSuffix(output, []byte(promptSuffix)) {
+		return len(p), nil
+	}
+	prompt := parsePrompt(output[w.checked:])
+	w.checked = len(output)
+	if w.prompter != nil {
+		if answer, err := w.prompter.Prompt(prompt); err == nil {
+			w.stdin.Write([]byte(answer + "\n"))
+			return len(p), nil
+		}
+	}
+	w.unanswered = &prompt
+	w.cancel()
+	return len(p), nil
+}
+
+// err returns the error of the question left unanswered, if any
+func (w *promptWatcher) err() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.unanswered == nil {
+		return nil
+	}
+	return promptError{prompt: *w.unanswered}
+}
+
+// parsePrompt reads the question before "Enter a value:", which is the last
+// paragraph of the output
+func parsePrompt(output []byte) Prompt {
+	text := string(bytes.TrimSuffix(output, []byte(promptSuffix)))
+	text = strings.TrimSpace(strings.Replace(text, "\r\n", "\n", -1))
+	if i := strings.LastIndex(text, "\n\n"); i >= 0 {
+		text = text[i+2:]
+	}
+	lines := strings.Split(text, "\n")
+	for i := range lines {
+		lines[i] = strings.TrimSpace(lines[i])
+	}
+	prompt := Prompt{Text: strings.Join(lines, "\n")}
+	if strings.HasPrefix(lines[0], "var.") {
+		prompt.Variable = strings.TrimPrefix(lines[0], "var.")
+	}
+	return prompt
+}
+
+// answers is the stdin of a command. Writes never block, so answering does not
+// depend on the executor reading them, and reads wait for the next answer
+type answers struct {
+	mu     sync.Mutex
+	cond   *sync.Cond
+	buf    bytes.Buffer
+	closed bool
+}
+
+func newAnswers() *answers {
+	a := &answers{}
+	a.cond = sync.NewCond(&a.mu)
+	return a
+}
+
+func (a *answers) Write(p []byte) (int, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if a.closed {
+		return 0, io.ErrClosedPipe
+	}
+	a.cond.Broadcast()
+	return a.buf.Write(p)
+}
+
+func (a *answers) Read(p []byte) (int, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	for a.buf.Len() == 0 && !a.closed {
+		a.cond.Wait()
+	}
+	if a.buf.Len() == 0 {
+		return 0, io.EOF
+	}
+	return a.buf.Read(p)
+}
+
+// Close ends the input, once the command has exited
+func (a *answers) Close() error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.closed = true
+	a.cond.Broadcast()
+	return nil
+}
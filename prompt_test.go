@@ -0,0 +1,165 @@
+package terralib
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+const applyPromptTest string = `
+Plan: 1 to add, 0 to change, 0 to destroy.
+
+Do you want to perform these actions?
+  Terraform will perform the actions described above.
+  Only 'yes' will be accepted to approve.
+
+  Enter a value: `
+
+const variablePromptTest string = `var.region
+  The AWS region to deploy to
+
+  Enter a value: `
+
+func TestParsePrompt(t *testing.T) {
+	tests := []struct {
+		output   string
+		expected Prompt
+	}{
+		{
+			output: applyPromptTest,
+			expected: Prompt{
+				Text: "Do you want to perform these actions?\n" +
+					"Terraform will perform the actions described above.\n" +
+					"Only 'yes' will be accepted to approve.",
+			},
+		},
+		{
+			output: variablePromptTest,
+			expected: Prompt{
+				Text:     "var.region\nThe AWS region to deploy to",
+				Variable: "region",
+			},
+		},
+	}
+	for _, test := range tests {
+		got := parsePrompt([]byte(strings.TrimRight(test.output, " ")))
+		if !cmp.Equal(got, test.expected) {
+			t.Errorf("Got: %+v, Expected: %+v", got, test.expected)
+		}
+	}
+}
+
+// promptingExecutor writes a question and waits for its answer, as terraform does
+func promptingExecutor(question string, answers *[]string) Executor {
+	return executorFunc(func(ctx context.Context, inv Invocation) (Result, error) {
+		io.WriteString(inv.Stdout, question)
+		lines := make(chan string, 1)
+		go func() {
+			line, _ := bufio.NewReader(inv.Stdin).ReadString('\n')
+			lines <- line
+		}()
+		select {
+		case line := <-lines:
+			*answers = append(*answers, strings.TrimSpace(line))
+			return Result{Output: []byte(question + line + "\nApply complete!")}, nil
+		case <-ctx.Done():
+			return Result{Output: []byte(question), ExitCode: -1}, ctx.Err()
+		}
+	})
+}
+
+func TestInteractivePrompt(t *testing.T) {
+	var answers []string
+	tf := Terralib{Executor: promptingExecutor(applyPromptTest, &answers)}
+	_, err := tf.Apply(nil)
+	applyError, ok := err.(ApplyError)
+	if !ok || applyError.Code != ErrInteractivePrompt {
+		t.Fatalf("Got: %v, Expected: %s", err, ErrInteractivePrompt)
+	}
+	if !strings.HasPrefix(applyError.Reason, "Do you want to perform these actions?") {
+		t.Errorf("Got: %q, Expected the prompt text", applyError.Reason)
+	}
+	if len(answers) > 0 {
+		t.Errorf("Got: %v, Expected no answers", answers)
+	}
+}
+
+func TestPrompter(t *testing.T) {
+	var answers []string
+	var prompts []Prompt
+	tf := Terralib{
+		Executor: promptingExecutor(applyPromptTest, &answers),
+		Prompter: PrompterFunc(func(prompt Prompt) (string, error) {
+			prompts = append(prompts, prompt)
+			return "yes", nil
+		}),
+	}
+	if _, err := tf.Apply(nil); err != nil {
+		t.Fatal(err)
+	}
+	if !cmp.Equal(answers, []string{"yes"}) || len(prompts) != 1 {
+		t.Errorf("Got: %v answers to %v, Expected the prompter to answer yes", answers, prompts)
+	}
+
+	answers = nil
+	tf.Prompter = PrompterFunc(func(prompt Prompt) (string, error) {
+		return "", errors.New("No answer")
+	})
+	_, err := tf.Apply(nil)
+	if applyError, ok := err.(ApplyError); !ok || applyError.Code != ErrInteractivePrompt {
+		t.Errorf("Got: %v, Expected: %s", err, ErrInteractivePrompt)
+	}
+}
+
+func TestShellExecutorPrompt(t *testing.T) {
+	dir, err := ioutil.TempDir("", "terralib")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	script := "#!/bin/sh\nprintf 'Do you want to perform these actions?\\n\\n  Enter a value: '\n" +
+		"read answer\necho \"answered $answer\"\n"
+	if err := ioutil.WriteFile(filepath.Join(dir, "terraform"), []byte(script), 0755); err != nil {
+		t.Fatal(err)
+	}
+	path := os.Getenv("PATH")
+	defer os.Setenv("PATH", path)
+	os.Setenv("PATH", dir+string(os.PathListSeparator)+path)
+
+	tf := Terralib{
+		Prompter: PrompterFunc(func(prompt Prompt) (string, error) {
+			return "yes", nil
+		}),
+	}
+	output, err := tf.Apply(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasSuffix(output.Raw, "answered yes\n") {
+		t.Errorf("Got: %q, Expected the answer to be read", output.Raw)
+	}
+
+	tf.Prompter = nil
+	done := make(chan error, 1)
+	go func() {
+		_, err := tf.Apply(nil)
+		done <- err
+	}()
+	select {
+	case err := <-done:
+		if applyError, ok := err.(ApplyError); !ok || applyError.Code != ErrInteractivePrompt {
+			t.Errorf("Got: %v, Expected: %s", err, ErrInteractivePrompt)
+		}
+	case <-time.After(10 * time.Second):
+		t.Fatal("Expected the command to be stopped when asking for input")
+	}
+}
@@ -0,0 +1,110 @@
+package terralib
+
+import (
+	"regexp"
+	"strings"
+)
+
+// Query selects resource changes of a plan, or resources of a plan or state,
+// matching all of its conditions. Empty conditions match everything
+type Query struct {
+	// Types are resource types like "aws_instance"
+	Types []string
+	// Modules are module addresses like "module.net", matching their
+	// instances and child modules too
+	Modules []string
+	// Pattern is a glob over the address, where * matches any text and ? any character
+	Pattern string
+	// Actions are change actions like ActionDelete. They only select changes
+	// and are ignored by Resources
+	Actions []string
+	// Providers are provider names, either in full like
+	// "registry.terraform.io/hashicorp/aws" or the last part like "aws"
+	Providers []string
+	// Mode is ModeManaged or ModeData
+	Mode string
+}
+
+// Changes returns the resource changes matching the query
+func (q Query) Changes(changes []ResourceChange) []ResourceChange {
+	pattern := q.pattern()
+	var matches []ResourceChange
+	for _, rc := range changes {
+		if q.matches(rc.Address, rc.Mode, rc.Type, rc.ProviderName, pattern) &&
+			(len(q.Actions) == 0 || contains(q.Actions, rc.Change.Action())) {
+			matches = append(matches, rc)
+		}
+	}
+	return matches
+}
+
+// Resources returns the resources matching the query, ignoring its Actions
+func (q Query) Resources(resources []Resource) []Resource {
+	pattern := q.pattern()
+	var matches []Resource
+	for _, r := range resources {
+		if q.matches(r.Address, r.Mode, r.Type, r.ProviderName, pattern) {
+			matches = append(matches, r)
+		}
+	}
+	return matches
+}
+
+func (q Query) matches(address string, mode string, resourceType string, provider string, pattern *regexp.Regexp) bool {
+	if q.Mode != "" && q.Mode != mode {
+		return false
+	}
+	if len(q.Types) > 0 && !contains(q.Types, resourceType) {
+		return false
+	}
+	if pattern != nil && !pattern.MatchString(address) {
+		return false
+	}
+	if len(q.Providers) > 0 && !matchesProvider(provider, q.Providers) {
+		return false
+	}
+	if len(q.Modules) > 0 {
+		parsed, err := ParseResourceAddress(address)
+		if err != nil || !matchesModule(parsed.ModuleAddress(), q.Modules) {
+			return false
+		}
+	}
+	return true
+}
+
+func (q Query) pattern() *regexp.Regexp {
+	if q.Pattern == "" {
+		return nil
+	}
+	expr := regexp.QuoteMeta(q.Pattern)
+	expr = strings.Replace(expr, `\*`, `.*`, -1)
+	expr = strings.Replace(expr, `\?`, `.`, -1)
+	return regexp.MustCompile("^" + expr + "$")
+}
+
+func matchesModule(module string, modules []string) bool {
+	for _, m := range modules {
+		if module == m || strings.HasPrefix(module, m+".") || strings.HasPrefix(module, m+"[") {
+			return true
+		}
+	}
+	return false
+}
+
+func matchesProvider(provider string, providers []string) bool {
+	for _, p := range providers {
+		if provider == p || strings.HasSuffix(provider, "/"+p) {
+			return true
+		}
+	}
+	return false
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
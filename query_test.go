@@ -0,0 +1,60 @@
+package terralib
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func addresses(changes []ResourceChange) []string {
+	var got []string
+	for _, rc := range changes {
+		got = append(got, rc.Address)
+	}
+	return got
+}
+
+func TestQueryChanges(t *testing.T) {
+	var plan ShowOutput
+	if err := json.Unmarshal([]byte(showOutputPlanTest), &plan); err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		query    Query
+		expected []string
+	}{
+		{Query{Types: []string{"aws_vpc"}}, []string{"module.net.aws_vpc.main"}},
+		{Query{Modules: []string{"module.net"}}, []string{"module.net.aws_vpc.main"}},
+		{Query{Pattern: "aws_s*"}, []string{"aws_s3_bucket.logs", "aws_security_group.web"}},
+		{Query{Actions: []string{ActionDelete, ActionReplace}}, []string{"aws_db_instance.main", "module.net.aws_vpc.main"}},
+		{Query{Providers: []string{"hashicorp/aws"}, Pattern: "*.main"}, []string{"aws_db_instance.main", "module.net.aws_vpc.main"}},
+		{Query{Providers: []string{"google"}}, nil},
+	}
+	for _, test := range tests {
+		got := addresses(test.query.Changes(plan.Changes))
+		if len(got) != len(test.expected) {
+			t.Errorf("%+v got: %v, Expected: %v", test.query, got, test.expected)
+			continue
+		}
+		for i := range got {
+			if got[i] != test.expected[i] {
+				t.Errorf("%+v got: %v, Expected: %v", test.query, got, test.expected)
+			}
+		}
+	}
+}
+
+func TestQueryResources(t *testing.T) {
+	var plan ShowOutput
+	if err := json.Unmarshal([]byte(showOutputPlanTest), &plan); err != nil {
+		t.Fatal(err)
+	}
+	got := Query{Modules: []string{"module.net"}}.Resources(plan.Planned.Resources())
+	if len(got) != 1 || got[0].Address != "module.net.aws_vpc.main" {
+		t.Errorf("Got: %+v, Expected: module.net.aws_vpc.main", got)
+	}
+	// Resources have no actions, so Actions are ignored
+	got = Query{Modules: []string{"module.net"}, Actions: []string{ActionCreate}}.Resources(plan.Planned.Resources())
+	if len(got) != 1 || got[0].Address != "module.net.aws_vpc.main" {
+		t.Errorf("Got: %+v, Expected: module.net.aws_vpc.main", got)
+	}
+}
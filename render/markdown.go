@@ -0,0 +1,63 @@
+package render
+
+import (
+	"fmt"
+	"strings"
+
+	terralib "github.com/amongil/terralib"
+)
+
+var actionTitles = map[string]string{
+	terralib.ActionCreate:  ":heavy_plus_sign: create",
+	terralib.ActionUpdate:  ":pencil2: update",
+	terralib.ActionDelete:  ":x: delete",
+	terralib.ActionReplace: ":recycle: replace",
+	terralib.ActionRead:    ":mag: read",
+}
+
+// Markdown renders a plan as GitHub-flavoured Markdown, with a table of the
+// changes and the diff of each resource in a collapsible section
+func Markdown(plan *terralib.ShowOutput) string {
+	var b strings.Builder
+	b.WriteString("### Terraform plan\n\n")
+	summary := terralib.Summarize(plan)
+	if len(summary.Changes) == 0 {
+		b.WriteString("No changes. Your infrastructure matches the configuration.\n")
+		return b.String()
+	}
+	fmt.Fprintf(&b, "**%s**\n\n", Summary(plan))
+	b.WriteString("| Action | Resource |\n")
+	b.WriteString("| --- | --- |\n")
+	for _, rc := range summary.Changes {
+		fmt.Fprintf(&b, "| %s | `%s` |\n", actionTitles[rc.Change.Action()], rc.Address)
+	}
+	for _, rc := range summary.Changes {
+		_, description := header(rc.Change)
+		fmt.Fprintf(&b, "\n<details><summary><code>%s</code> %s</summary>\n\n", rc.Address, description)
+		b.WriteString("```diff\n")
+		b.WriteString(diffLines(Change(rc)))
+		b.WriteString("```\n\n</details>\n")
+	}
+	return b.String()
+}
+
+// diffLines moves the change symbol of each line to the first column, so
+// GitHub highlights added and removed lines
+func diffLines(text string) string {
+	var b strings.Builder
+	for _, line := range strings.SplitAfter(text, "\n") {
+		trimmed := strings.TrimLeft(line, " ")
+		if strings.HasPrefix(line, "  # ") || len(trimmed) < 2 {
+			continue
+		}
+		if i := len(line) - len(trimmed); i > 0 && strings.ContainsAny(trimmed[:1], "+-~") && trimmed[1] == ' ' {
+			symbol := trimmed[:1]
+			if symbol == "~" {
+				symbol = "!"
+			}
+			line = symbol + line[1:i] + " " + trimmed[1:]
+		}
+		b.WriteString(line)
+	}
+	return b.String()
+}
@@ -0,0 +1,326 @@
+// Package render turns the changes of a terraform plan into text similar to
+// the one printed by terraform, or into Markdown to post them on pull requests
+package render
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+
+	terralib "github.com/amongil/terralib"
+)
+
+const (
+	knownAfterApply = "(known after apply)"
+	sensitiveValue  = "(sensitive value)"
+)
+
+// Text renders the resource changes of a plan followed by its summary
+func Text(plan *terralib.ShowOutput) string {
+	var b strings.Builder
+	changed := false
+	for _, rc := range plan.Changes {
+		if text := Change(rc); text != "" {
+			b.WriteString(text)
+			b.WriteString("\n")
+			changed = true
+		}
+	}
+	if !changed {
+		return "No changes. Your infrastructure matches the configuration.\n"
+	}
+	b.WriteString(Summary(plan))
+	b.WriteString("\n")
+	return b.String()
+}
+
+// Summary returns the "Plan: X to add, Y to change, Z to destroy." line of a plan
+func Summary(plan *terralib.ShowOutput) string {
+	s := terralib.Summarize(plan)
+	return fmt.Sprintf("Plan: %d to add, %d to change, %d to destroy.",
+		s.Create+s.Replace, s.Update, s.Delete+s.Replace)
+}
+
+// Change renders the change of a resource, or an empty string if it does not change
+func Change(rc terralib.ResourceChange) string {
+	symbol, description := header(rc.Change)
+	if symbol == "" {
+		return ""
+	}
+	var b strings.Builder
+	fmt.Fprintf(&b, "  # %s %s\n", rc.Address, description)
+	keyword := "resource"
+	if rc.Mode == "data" {
+		keyword = "data"
+	}
+	fmt.Fprintf(&b, "%3s %s %q %q {\n", symbol, keyword, rc.Type, rc.Name)
+	r := renderer{
+		b:            &b,
+		replacePaths: rc.Change.ReplacePaths,
+	}
+	r.attributes(1, nil, asMap(rc.Change.Before), asMap(rc.Change.After), rc.Change.AfterUnknown,
+		rc.Change.BeforeSensitive, rc.Change.AfterSensitive)
+	b.WriteString("    }\n")
+	return b.String()
+}
+
+func header(change terralib.Change) (string, string) {
+	switch change.Action() {
+	case terralib.ActionCreate:
+		return "+", "will be created"
+	case terralib.ActionUpdate:
+		return "~", "will be updated in-place"
+	case terralib.ActionDelete:
+		return "-", "will be destroyed"
+	case terralib.ActionRead:
+		return "<=", "will be read during apply"
+	case terralib.ActionReplace:
+		if change.Actions[0] == terralib.ActionCreate {
+			return "+/-", "must be replaced"
+		}
+		return "-/+", "must be replaced"
+	}
+	return "", ""
+}
+
+type renderer struct {
+	b            *strings.Builder
+	replacePaths [][]interface{}
+}
+
+// attributes renders the attributes of an object at the given depth, hiding
+// the ones that do not change
+func (r *renderer) attributes(depth int, path []interface{}, before, after map[string]interface{},
+	unknown, beforeSensitive, afterSensitive interface{}) {
+	keys := map[string]bool{}
+	for k := range before {
+		keys[k] = true
+	}
+	for k := range after {
+		keys[k] = true
+	}
+	unknownMap := asMap(unknown)
+	for k := range unknownMap {
+		keys[k] = true
+	}
+	var changed []string
+	width := 0
+	for k := range keys {
+		if isChanged(before[k], after[k], unknownMap[k]) {
+			changed = append(changed, k)
+			if len(k) > width {
+				width = len(k)
+			}
+		}
+	}
+	sort.Strings(changed)
+	bs := asMap(beforeSensitive)
+	as := asMap(afterSensitive)
+	for _, k := range changed {
+		key := fmt.Sprintf("%-*s", width, k)
+		r.value(depth, append(path, k), key+" = ", "", before[k], after[k], unknownMap[k], bs[k], as[k])
+	}
+	if hidden := len(keys) - len(changed); hidden > 0 && before != nil && after != nil {
+		noun := "attributes"
+		if hidden == 1 {
+			noun = "attribute"
+		}
+		fmt.Fprintf(r.b, "%s# (%d unchanged %s hidden)\n", column(depth), hidden, noun)
+	}
+}
+
+// value renders a value that changes, between a prefix with its key if it has
+// one and a suffix
+func (r *renderer) value(depth int, path []interface{}, prefix string, suffix string, before, after interface{},
+	unknown, beforeSensitive, afterSensitive interface{}) {
+	symbol := "~"
+	switch {
+	case before == nil && (after != nil || unknown == true):
+		symbol = "+"
+	case after == nil && unknown != true:
+		symbol = "-"
+	}
+	comment := suffix
+	if r.forcesReplacement(path) {
+		comment += " # forces replacement"
+	}
+	line := indent(depth) + symbol + " " + prefix
+	if beforeSensitive == true || afterSensitive == true {
+		fmt.Fprintf(r.b, "%s%s%s\n", line, sensitiveValue, comment)
+		return
+	}
+	if unknown == true {
+		if before == nil {
+			fmt.Fprintf(r.b, "%s%s%s\n", line, knownAfterApply, comment)
+		} else {
+			fmt.Fprintf(r.b, "%s%s -> %s%s\n", line, literal(before), knownAfterApply, comment)
+		}
+		return
+	}
+	if isCollection(before) || isCollection(after) {
+		r.collection(depth, path, line, comment, suffix, before, after, unknown, beforeSensitive, afterSensitive)
+		return
+	}
+	if symbol == "~" && suffix != "" {
+		// Changed list elements are shown as removed and added
+		fmt.Fprintf(r.b, "%s- %s%s%s\n", indent(depth), literal(before), suffix, comment[len(suffix):])
+		fmt.Fprintf(r.b, "%s+ %s%s%s\n", indent(depth), literal(after), suffix, comment[len(suffix):])
+		return
+	}
+	switch symbol {
+	case "+":
+		fmt.Fprintf(r.b, "%s%s%s\n", line, literal(after), comment)
+	case "-":
+		fmt.Fprintf(r.b, "%s%s -> null%s\n", line, literal(before), comment)
+	default:
+		fmt.Fprintf(r.b, "%s%s -> %s%s\n", line, literal(before), literal(after), comment)
+	}
+}
+
+// collection renders a map or list value with the diff of its elements
+func (r *renderer) collection(depth int, path []interface{}, line string, comment string, suffix string, before, after interface{},
+	unknown, beforeSensitive, afterSensitive interface{}) {
+	open, close := "{", "}"
+	if _, ok := before.([]interface{}); ok {
+		open, close = "[", "]"
+	}
+	if _, ok := after.([]interface{}); ok {
+		open, close = "[", "]"
+	}
+	fmt.Fprintf(r.b, "%s%s%s\n", line, open, comment[len(suffix):])
+	if open == "{" {
+		r.attributes(depth+1, path, asMap(before), asMap(after), unknown, beforeSensitive, afterSensitive)
+	} else {
+		r.elements(depth+1, path, asList(before), asList(after), asList(unknown), asList(beforeSensitive), asList(afterSensitive))
+	}
+	fmt.Fprintf(r.b, "%s%s%s\n", column(depth), close, suffix)
+}
+
+// elements renders the elements of a list that change, by position
+func (r *renderer) elements(depth int, path []interface{}, before, after, unknown, beforeSensitive, afterSensitive []interface{}) {
+	n := len(before)
+	if len(after) > n {
+		n = len(after)
+	}
+	if len(unknown) > n {
+		n = len(unknown)
+	}
+	hidden := 0
+	for i := 0; i < n; i++ {
+		b, a, u := at(before, i), at(after, i), at(unknown, i)
+		if !isChanged(b, a, u) {
+			hidden++
+			continue
+		}
+		r.value(depth, append(path, i), "", ",", b, a, u, at(beforeSensitive, i), at(afterSensitive, i))
+	}
+	if hidden > 0 && before != nil && after != nil {
+		noun := "elements"
+		if hidden == 1 {
+			noun = "element"
+		}
+		fmt.Fprintf(r.b, "%s# (%d unchanged %s hidden)\n", column(depth), hidden, noun)
+	}
+}
+
+func (r *renderer) forcesReplacement(path []interface{}) bool {
+	for _, p := range r.replacePaths {
+		if len(p) != len(path) {
+			continue
+		}
+		match := true
+		for i := range p {
+			if fmt.Sprint(p[i]) != fmt.Sprint(path[i]) {
+				match = false
+				break
+			}
+		}
+		if match {
+			return true
+		}
+	}
+	return false
+}
+
+func isChanged(before, after, unknown interface{}) bool {
+	if unknown == true {
+		return true
+	}
+	if u, ok := unknown.(map[string]interface{}); ok {
+		for _, e := range u {
+			if isChanged(nil, nil, e) {
+				return true
+			}
+		}
+	}
+	if u, ok := unknown.([]interface{}); ok {
+		for _, e := range u {
+			if isChanged(nil, nil, e) {
+				return true
+			}
+		}
+	}
+	return !reflect.DeepEqual(before, after)
+}
+
+func isCollection(v interface{}) bool {
+	switch v.(type) {
+	case map[string]interface{}, []interface{}:
+		return true
+	}
+	return false
+}
+
+func asMap(v interface{}) map[string]interface{} {
+	m, _ := v.(map[string]interface{})
+	return m
+}
+
+func asList(v interface{}) []interface{} {
+	l, _ := v.([]interface{})
+	return l
+}
+
+func at(l []interface{}, i int) interface{} {
+	if i < len(l) {
+		return l[i]
+	}
+	return nil
+}
+
+// indent returns the indentation of the change symbol of a value at the given depth
+func indent(depth int) string {
+	return strings.Repeat(" ", 4*depth+2)
+}
+
+// column returns the indentation of the values at the given depth, after
+// their change symbols
+func column(depth int) string {
+	return strings.Repeat(" ", 4*depth+4)
+}
+
+// literal formats a primitive value like terraform does
+func literal(v interface{}) string {
+	switch value := v.(type) {
+	case nil:
+		return "null"
+	case string:
+		if strings.Contains(value, "\n") {
+			return "<<-EOT\n" + value + "\nEOT"
+		}
+	case map[string]interface{}, []interface{}:
+		if v := reflect.ValueOf(value); v.Len() == 0 {
+			if _, ok := value.([]interface{}); ok {
+				return "[]"
+			}
+			return "{}"
+		}
+	}
+	var b strings.Builder
+	encoder := json.NewEncoder(&b)
+	encoder.SetEscapeHTML(false)
+	encoder.Encode(v)
+	return strings.TrimSuffix(b.String(), "\n")
+}
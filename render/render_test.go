@@ -0,0 +1,185 @@
+package render
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	terralib "github.com/amongil/terralib"
+)
+
+const planTest string = `{
+  "format_version": "1.2",
+  "resource_changes": [
+    {
+      "address": "aws_instance.web",
+      "mode": "managed",
+      "type": "aws_instance",
+      "name": "web",
+      "provider_name": "registry.terraform.io/hashicorp/aws",
+      "change": {
+        "actions": ["create"],
+        "before": null,
+        "after": {"ami": "ami-0c55b159", "instance_type": "t3.micro", "tags": {"Name": "web"}, "password": "hunter2"},
+        "after_unknown": {"id": true, "arn": true, "tags": {}},
+        "before_sensitive": false,
+        "after_sensitive": {"password": true, "tags": {}}
+      }
+    },
+    {
+      "address": "aws_instance.db",
+      "mode": "managed",
+      "type": "aws_instance",
+      "name": "db",
+      "provider_name": "registry.terraform.io/hashicorp/aws",
+      "change": {
+        "actions": ["update"],
+        "before": {"id": "i-0a1b", "instance_type": "t2.micro", "tags": {"Name": "db", "env": "dev"}},
+        "after": {"id": "i-0a1b", "instance_type": "t3.micro", "tags": {"Name": "db", "env": "prod"}},
+        "after_unknown": {"tags": {}},
+        "before_sensitive": {},
+        "after_sensitive": {}
+      }
+    },
+    {
+      "address": "aws_instance.app[0]",
+      "mode": "managed",
+      "type": "aws_instance",
+      "name": "app",
+      "index": 0,
+      "provider_name": "registry.terraform.io/hashicorp/aws",
+      "change": {
+        "actions": ["delete", "create"],
+        "before": {"id": "i-0c3d", "ami": "ami-1111", "security_groups": ["sg-1", "sg-2"]},
+        "after": {"ami": "ami-2222", "security_groups": ["sg-1", "sg-3"]},
+        "after_unknown": {"id": true, "security_groups": [false, false]},
+        "before_sensitive": {},
+        "after_sensitive": {},
+        "replace_paths": [["ami"]]
+      }
+    },
+    {
+      "address": "aws_s3_bucket.old",
+      "mode": "managed",
+      "type": "aws_s3_bucket",
+      "name": "old",
+      "provider_name": "registry.terraform.io/hashicorp/aws",
+      "change": {
+        "actions": ["delete"],
+        "before": {"bucket": "old-logs", "id": "old-logs"},
+        "after": null,
+        "after_unknown": {},
+        "before_sensitive": {},
+        "after_sensitive": false
+      }
+    },
+    {
+      "address": "aws_s3_bucket.logs",
+      "mode": "managed",
+      "type": "aws_s3_bucket",
+      "name": "logs",
+      "provider_name": "registry.terraform.io/hashicorp/aws",
+      "change": {"actions": ["no-op"], "before": {"bucket": "logs"}, "after": {"bucket": "logs"}}
+    }
+  ]
+}`
+
+const textTest string = `  # aws_instance.web will be created
+  + resource "aws_instance" "web" {
+      + ami           = "ami-0c55b159"
+      + arn           = (known after apply)
+      + id            = (known after apply)
+      + instance_type = "t3.micro"
+      + password      = (sensitive value)
+      + tags          = {
+          + Name = "web"
+        }
+    }
+
+  # aws_instance.db will be updated in-place
+  ~ resource "aws_instance" "db" {
+      ~ instance_type = "t2.micro" -> "t3.micro"
+      ~ tags          = {
+          ~ env = "dev" -> "prod"
+            # (1 unchanged attribute hidden)
+        }
+        # (1 unchanged attribute hidden)
+    }
+
+  # aws_instance.app[0] must be replaced
+-/+ resource "aws_instance" "app" {
+      ~ ami             = "ami-1111" -> "ami-2222" # forces replacement
+      ~ id              = "i-0c3d" -> (known after apply)
+      ~ security_groups = [
+          - "sg-2",
+          + "sg-3",
+            # (1 unchanged element hidden)
+        ]
+    }
+
+  # aws_s3_bucket.old will be destroyed
+  - resource "aws_s3_bucket" "old" {
+      - bucket = "old-logs" -> null
+      - id     = "old-logs" -> null
+    }
+
+Plan: 2 to add, 1 to change, 2 to destroy.
+`
+
+const markdownChangeTest string = `<details><summary><code>aws_instance.app[0]</code> must be replaced</summary>
+
+` + "```diff" + `
+-/+ resource "aws_instance" "app" {
+!       ami             = "ami-1111" -> "ami-2222" # forces replacement
+!       id              = "i-0c3d" -> (known after apply)
+!       security_groups = [
+-           "sg-2",
++           "sg-3",
+            # (1 unchanged element hidden)
+        ]
+    }
+` + "```" + `
+
+</details>
+`
+
+func newPlanTest(t *testing.T) *terralib.ShowOutput {
+	var plan terralib.ShowOutput
+	if err := json.Unmarshal([]byte(planTest), &plan); err != nil {
+		t.Fatal(err)
+	}
+	return &plan
+}
+
+func TestText(t *testing.T) {
+	got := Text(newPlanTest(t))
+	if got != textTest {
+		t.Errorf("Got:\n%s\nExpected:\n%s", got, textTest)
+	}
+}
+
+func TestTextNoChanges(t *testing.T) {
+	plan := newPlanTest(t)
+	plan.Changes = plan.Changes[4:]
+	expected := "No changes. Your infrastructure matches the configuration.\n"
+	if got := Text(plan); got != expected {
+		t.Errorf("Got: %q, Expected: %q", got, expected)
+	}
+}
+
+func TestMarkdown(t *testing.T) {
+	got := Markdown(newPlanTest(t))
+	expected := []string{
+		"**Plan: 2 to add, 1 to change, 2 to destroy.**\n",
+		"| :recycle: replace | `aws_instance.app[0]` |\n",
+		markdownChangeTest,
+	}
+	for _, e := range expected {
+		if !strings.Contains(got, e) {
+			t.Errorf("Got:\n%s\nExpected it to contain:\n%s", got, e)
+		}
+	}
+	if strings.Contains(got, "aws_s3_bucket.logs") {
+		t.Errorf("Expected resources without changes to be left out")
+	}
+}
@@ -0,0 +1,122 @@
+package terralib
+
+import (
+	"context"
+	"math/rand"
+	"time"
+)
+
+// Exported error codes
+const (
+	ErrThrottled string = "errThrottled"
+)
+
+// sleep waits between attempts of a command, failing when ctx is cancelled
+var sleep = func(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// RetryPolicy sets how a command is run again when it fails with a transient error
+type RetryPolicy struct {
+	// MaxAttempts is the maximum number of runs, including the first one
+	MaxAttempts int
+	// Backoff is the wait before the first retry, doubled on every retry up to MaxBackoff
+	Backoff    time.Duration
+	MaxBackoff time.Duration
+	// Jitter randomizes each wait by up to the given fraction, from 0 to 1
+	Jitter float64
+	// Codes are the error codes that are retried
+	Codes []string
+	// OnRetry is called before each retry, before the OnRetry hooks
+	OnRetry func(RetryEvent)
+}
+
+// RetryEvent represents a failed attempt that is going to be retried
+type RetryEvent struct {
+	Command string
+	Attempt int
+	Code    string
+	Reason  string
+	Delay   time.Duration
+}
+
+// Attempt represents a single run of a command
+type Attempt struct {
+	Raw      string
+	Code     string
+	Duration time.Duration
+}
+
+// DefaultInitRetryPolicy returns a policy retrying init on registry and
+// provider download failures
+func DefaultInitRetryPolicy() *RetryPolicy {
+	return &RetryPolicy{
+		MaxAttempts: 3,
+		Backoff:     5 * time.Second,
+		MaxBackoff:  time.Minute,
+		Jitter:      0.2,
+		Codes: []string{
+			ErrDiscoveryServiceUnreachable,
+			ErrProviderInstallError,
+		},
+	}
+}
+
+// DefaultApplyRetryPolicy returns a policy retrying apply when the provider
+// API throttles requests. An apply of a saved plan is not retried, see
+// Terralib.Retry
+func DefaultApplyRetryPolicy() *RetryPolicy {
+	return &RetryPolicy{
+		MaxAttempts: 3,
+		Backoff:     30 * time.Second,
+		MaxBackoff:  5 * time.Minute,
+		Jitter:      0.2,
+		Codes: []string{
+			ErrThrottled,
+		},
+	}
+}
+
+// retryPolicy returns the retry policy of a command. An apply of a saved plan
+// has none: once it has changed a resource the plan is stale, so a retry
+// would fail with ErrSavedPlanStale
+func (t *Terralib) retryPolicy(command string, options []string) *RetryPolicy {
+	if command == CommandApply && applyPlanFile(options) != "" {
+		return nil
+	}
+	return t.Retry[command]
+}
+
+func (p *RetryPolicy) retryable(code string, attempt int) bool {
+	if p == nil || attempt >= p.MaxAttempts {
+		return false
+	}
+	for _, c := range p.Codes {
+		if c == code {
+			return true
+		}
+	}
+	return false
+}
+
+// delay returns the wait before retrying the given attempt, counting from 1
+func (p *RetryPolicy) delay(attempt int) time.Duration {
+	d := p.Backoff
+	for i := 1; i < attempt && (p.MaxBackoff <= 0 || d < p.MaxBackoff); i++ {
+		d *= 2
+	}
+	if p.MaxBackoff > 0 && d > p.MaxBackoff {
+		d = p.MaxBackoff
+	}
+	if p.Jitter > 0 {
+		d += time.Duration((rand.Float64()*2 - 1) * p.Jitter * float64(d))
+	}
+	return d
+}
@@ -0,0 +1,195 @@
+package terralib
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+const applyOutputErrThrottledTest string = `
+aws_route53_record.www: Creating...
+
+Error: error creating Route53 Record: Throttling: Rate exceeded
+	status code: 400, request id: 0f3c5c4e-1d2a-4b6e-8f0a-2c4e6a8b0d1f
+
+  with aws_route53_record.www,
+  on dns.tf line 3, in resource "aws_route53_record" "www":
+   3: resource "aws_route53_record" "www" {
+`
+
+func TestFindApplyErrThrottled(t *testing.T) {
+	expected := ApplyError{
+		Reason:  "error creating Route53 Record: Throttling: Rate exceeded",
+		Code:    "errThrottled",
+		Address: "aws_route53_record.www",
+	}
+	got := findApplyError([]byte(applyOutputErrThrottledTest))
+	if !cmp.Equal(got, expected) {
+		t.Errorf("Got: %+v, Expected: %+v", got, expected)
+	}
+}
+
+func TestRetryPolicyRetryable(t *testing.T) {
+	policy := DefaultInitRetryPolicy()
+	tests := []struct {
+		code     string
+		attempt  int
+		expected bool
+	}{
+		{ErrDiscoveryServiceUnreachable, 1, true},
+		{ErrDiscoveryServiceUnreachable, 3, false},
+		{ErrProviderNotFound, 1, false},
+	}
+	for _, test := range tests {
+		got := policy.retryable(test.code, test.attempt)
+		if got != test.expected {
+			t.Errorf("%s attempt %d got: %v, Expected: %v", test.code, test.attempt, got, test.expected)
+		}
+	}
+	var none *RetryPolicy
+	if none.retryable(ErrDiscoveryServiceUnreachable, 1) {
+		t.Errorf("Expected no retries without a policy")
+	}
+}
+
+func TestRetryPolicyDelay(t *testing.T) {
+	policy := RetryPolicy{
+		Backoff:    time.Second,
+		MaxBackoff: 5 * time.Second,
+	}
+	expected := []time.Duration{time.Second, 2 * time.Second, 4 * time.Second, 5 * time.Second}
+	for i, e := range expected {
+		got := policy.delay(i + 1)
+		if got != e {
+			t.Errorf("Attempt %d got: %v, Expected: %v", i+1, got, e)
+		}
+	}
+	policy.Jitter = 0.5
+	for i := 0; i < 100; i++ {
+		got := policy.delay(1)
+		if got < 500*time.Millisecond || got > 1500*time.Millisecond {
+			t.Errorf("Got: %v, Expected a delay between 0.5s and 1.5s", got)
+		}
+	}
+}
+
+// recordSleeps replaces sleep with a function recording the waits
+func recordSleeps(t *testing.T) *[]time.Duration {
+	var sleeps []time.Duration
+	sleepBefore := sleep
+	sleep = func(ctx context.Context, d time.Duration) error {
+		sleeps = append(sleeps, d)
+		return ctx.Err()
+	}
+	t.Cleanup(func() {
+		sleep = sleepBefore
+	})
+	return &sleeps
+}
+
+func TestExecuteRetries(t *testing.T) {
+	sleeps := recordSleeps(t)
+	runs := 0
+	var policyEvents, hookEvents []RetryEvent
+	tf := Terralib{
+		Executor: failingExecutor(applyOutputErrThrottledTest, 2, &runs),
+		Retry: map[string]*RetryPolicy{
+			CommandApply: {
+				MaxAttempts: 5,
+				Backoff:     time.Second,
+				MaxBackoff:  10 * time.Second,
+				Codes:       []string{ErrThrottled},
+				OnRetry: func(event RetryEvent) {
+					policyEvents = append(policyEvents, event)
+				},
+			},
+		},
+		Hooks: []Hooks{{
+			OnRetry: func(event RetryEvent) {
+				hookEvents = append(hookEvents, event)
+			},
+		}},
+	}
+	output, err := tf.Apply([]string{"-auto-approve"})
+	if err != nil {
+		t.Fatalf("Got error: %v", err)
+	}
+	if runs != 3 || len(output.Attempts) != 3 || output.Attempts[0].Code != ErrThrottled || output.Attempts[2].Code != "" {
+		t.Errorf("Got %d runs and attempts %+v, Expected 3", runs, output.Attempts)
+	}
+	if expected := []time.Duration{time.Second, 2 * time.Second}; !cmp.Equal(*sleeps, expected) {
+		t.Errorf("Got sleeps: %v, Expected: %v", *sleeps, expected)
+	}
+	expected := []RetryEvent{
+		{Command: CommandApply, Attempt: 1, Code: ErrThrottled, Reason: "error creating Route53 Record: Throttling: Rate exceeded", Delay: time.Second},
+		{Command: CommandApply, Attempt: 2, Code: ErrThrottled, Reason: "error creating Route53 Record: Throttling: Rate exceeded", Delay: 2 * time.Second},
+	}
+	if !cmp.Equal(policyEvents, expected) || !cmp.Equal(hookEvents, expected) {
+		t.Errorf("Got: %+v and %+v, Expected: %+v", policyEvents, hookEvents, expected)
+	}
+}
+
+func TestExecuteRetriesMaxAttempts(t *testing.T) {
+	sleeps := recordSleeps(t)
+	runs := 0
+	tf := Terralib{
+		Executor: failingExecutor(applyOutputErrThrottledTest, 10, &runs),
+		Retry: map[string]*RetryPolicy{
+			CommandApply: {MaxAttempts: 3, Backoff: time.Second, Codes: []string{ErrThrottled}},
+		},
+	}
+	output, err := tf.Apply([]string{"-auto-approve"})
+	if applyErr, ok := err.(ApplyError); !ok || applyErr.Code != ErrThrottled {
+		t.Errorf("Got: %v, Expected: %s", err, ErrThrottled)
+	}
+	if runs != 3 || len(output.Attempts) != 3 || len(*sleeps) != 2 {
+		t.Errorf("Got %d runs, %d attempts and %d sleeps, Expected 3 runs", runs, len(output.Attempts), len(*sleeps))
+	}
+}
+
+func TestExecuteRetriesCancelled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	runs := 0
+	tf := Terralib{
+		Context:  ctx,
+		Executor: failingExecutor(applyOutputErrThrottledTest, 10, &runs),
+		Retry: map[string]*RetryPolicy{
+			CommandApply: {MaxAttempts: 3, Backoff: time.Hour, Codes: []string{ErrThrottled}},
+		},
+		Hooks: []Hooks{{
+			// Cancelled while waiting to retry
+			OnRetry: func(event RetryEvent) {
+				go cancel()
+			},
+		}},
+	}
+	start := time.Now()
+	_, err := tf.Apply([]string{"-auto-approve"})
+	if applyErr, ok := err.(ApplyError); !ok || applyErr.Code != ErrThrottled {
+		t.Errorf("Got: %v, Expected: %s", err, ErrThrottled)
+	}
+	if runs != 1 || time.Since(start) > time.Minute {
+		t.Errorf("Got %d runs after %v, Expected the backoff to stop", runs, time.Since(start))
+	}
+}
+
+func TestExecuteRetriesSavedPlan(t *testing.T) {
+	sleeps := recordSleeps(t)
+	runs := 0
+	tf := Terralib{
+		Executor: failingExecutor(applyOutputErrThrottledTest, 1, &runs),
+		Retry: map[string]*RetryPolicy{
+			CommandApply: DefaultApplyRetryPolicy(),
+		},
+	}
+	_, err := tf.Apply([]string{"plan.tfplan"})
+	if applyErr, ok := err.(ApplyError); !ok || applyErr.Code != ErrThrottled {
+		t.Errorf("Got: %v, Expected: %s", err, ErrThrottled)
+	}
+	if runs != 1 || len(*sleeps) != 0 {
+		t.Errorf("Got %d runs and sleeps %v, Expected a saved plan not to be retried", runs, *sleeps)
+	}
+}
@@ -0,0 +1,272 @@
+package terralib
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// Exported error codes
+const (
+	ErrRunNotApproved  string = "errRunNotApproved"
+	ErrRunRejected     string = "errRunRejected"
+	ErrPlanFileChanged string = "errPlanFileChanged"
+	ErrConfigChanged   string = "errConfigChanged"
+)
+
+// configExtensions are the files that make up the fingerprint of a configuration
+var configExtensions = []string{".tf", ".tf.json", ".tfvars", ".tfvars.json", ".terraform.lock.hcl"}
+
+// ChangeSummary represents the changes of a plan by action
+type ChangeSummary struct {
+	Create  int
+	Update  int
+	Delete  int
+	Replace int
+	Changes []ResourceChange
+}
+
+// Approver decides whether the changes of a run can be applied
+type Approver interface {
+	Approve(summary ChangeSummary) (bool, error)
+}
+
+// ApproverFunc allows to use a function as an Approver
+type ApproverFunc func(summary ChangeSummary) (bool, error)
+
+// Approve calls f(summary)
+func (f ApproverFunc) Approve(summary ChangeSummary) (bool, error) {
+	return f(summary)
+}
+
+// Run represents a saved plan going through review before it is applied.
+// ApprovedSHA256 is the SHA-256 of the plan file when it was approved, which
+// ApplyRun checks along with Approved
+type Run struct {
+	PlanFile          string
+	PlanSHA256        string
+	ConfigFingerprint string
+	Plan              *ShowOutput
+	Summary           ChangeSummary
+	Approved          bool
+	ApprovedSHA256    string
+}
+
+// RunError represents an error approving or applying a run
+type RunError struct {
+	Reason string
+	Code   string
+}
+
+func (e RunError) Error() string {
+	return e.Code
+}
+
+// PlanRun executes the 'terraform plan' command saving the plan to planFile,
+// and returns a run capturing the plan to approve and apply it. An -out
+// option is replaced by planFile
+func (t *Terralib) PlanRun(planFile string, options []string) (*Run, PlanOutput, error) {
+	options = append(withoutOutOption(options), "-out="+planFile)
+	planOutput, err := t.Plan(options)
+	if err != nil {
+		return nil, planOutput, err
+	}
+	run := &Run{
+		PlanFile: planFile,
+		Plan:     planOutput.Plan,
+	}
+	if run.Plan == nil {
+		plan, err := t.Show(planFile)
+		if err != nil {
+			return nil, planOutput, err
+		}
+		run.Plan = &plan
+	}
+	run.Summary = Summarize(run.Plan)
+	if run.PlanSHA256, err = fileSHA256(t.path(planFile)); err != nil {
+		return nil, planOutput, err
+	}
+	if run.ConfigFingerprint, err = ConfigFingerprint(t.ConfigPath); err != nil {
+		return nil, planOutput, err
+	}
+	return run, planOutput, nil
+}
+
+// ApproveRun checks the run has not changed since it was planned and asks
+// the approver to approve its changes
+func (t *Terralib) ApproveRun(run *Run, approver Approver) error {
+	if err := t.verifyRun(run); err != nil {
+		return err
+	}
+	approved, err := approver.Approve(run.Summary)
+	if err != nil {
+		return err
+	}
+	if !approved {
+		return RunError{
+			Reason: "The changes were rejected",
+			Code:   ErrRunRejected,
+		}
+	}
+	run.Approved = true
+	run.ApprovedSHA256 = run.PlanSHA256
+	return nil
+}
+
+// ApplyRun executes the 'terraform apply' command on the plan file of an
+// approved run, refusing to do so if the plan file or the configuration have
+// changed since it was planned
+func (t *Terralib) ApplyRun(run *Run, options []string) (ApplyOutput, error) {
+	if !run.Approved || run.ApprovedSHA256 == "" {
+		return ApplyOutput{}, RunError{
+			Reason: "The run has not been approved",
+			Code:   ErrRunNotApproved,
+		}
+	}
+	if run.ApprovedSHA256 != run.PlanSHA256 {
+		return ApplyOutput{}, RunError{
+			Reason: "The plan file " + run.PlanFile + " has changed since it was approved",
+			Code:   ErrPlanFileChanged,
+		}
+	}
+	if err := t.verifyRun(run); err != nil {
+		return ApplyOutput{}, err
+	}
+	return t.Apply(append(append([]string(nil), options...), run.PlanFile))
+}
+
+// withoutOutOption returns a copy of the options without -out, given as
+// -out=file or -out file
+func withoutOutOption(options []string) []string {
+	_, kept := splitOutOption(options)
+	return kept
+}
+
+// splitOutOption returns the plan file of the last -out option, given as
+// -out=file or -out file as terraform keeps the last one, and a copy of the
+// other options
+func splitOutOption(options []string) (string, []string) {
+	var planFile string
+	var kept []string
+	for i := 0; i < len(options); i++ {
+		switch {
+		case options[i] == "-out":
+			if i+1 < len(options) {
+				planFile = options[i+1]
+			}
+			i++
+		case strings.HasPrefix(options[i], "-out="):
+			planFile = strings.TrimPrefix(options[i], "-out=")
+		default:
+			kept = append(kept, options[i])
+		}
+	}
+	return planFile, kept
+}
+
+func (t *Terralib) verifyRun(run *Run) error {
+	sum, err := fileSHA256(t.path(run.PlanFile))
+	if err != nil {
+		return err
+	}
+	if sum != run.PlanSHA256 {
+		return RunError{
+			Reason: "The plan file " + run.PlanFile + " has changed since it was planned",
+			Code:   ErrPlanFileChanged,
+		}
+	}
+	fingerprint, err := ConfigFingerprint(t.ConfigPath)
+	if err != nil {
+		return err
+	}
+	if fingerprint != run.ConfigFingerprint {
+		return RunError{
+			Reason: "The configuration has changed since it was planned",
+			Code:   ErrConfigChanged,
+		}
+	}
+	return nil
+}
+
+// path returns the path of a file relative to the configuration path
+func (t *Terralib) path(name string) string {
+	if filepath.IsAbs(name) {
+		return name
+	}
+	return filepath.Join(t.ConfigPath, name)
+}
+
+// Summarize counts the changes of a plan by action
+func Summarize(plan *ShowOutput) ChangeSummary {
+	var summary ChangeSummary
+	for _, rc := range plan.Changes {
+		switch rc.Change.Action() {
+		case ActionCreate:
+			summary.Create++
+		case ActionUpdate:
+			summary.Update++
+		case ActionDelete:
+			summary.Delete++
+		case ActionReplace:
+			summary.Replace++
+		default:
+			continue
+		}
+		summary.Changes = append(summary.Changes, rc)
+	}
+	return summary
+}
+
+// ConfigFingerprint returns the SHA-256 of the configuration files in a
+// directory and its subdirectories, ignoring the .terraform directory
+func ConfigFingerprint(dir string) (string, error) {
+	var files []string
+	err := filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() {
+			if path != dir && strings.HasPrefix(info.Name(), ".") {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		for _, ext := range configExtensions {
+			if strings.HasSuffix(info.Name(), ext) {
+				files = append(files, path)
+				break
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return "", err
+	}
+	sort.Strings(files)
+	h := sha256.New()
+	for _, file := range files {
+		content, err := ioutil.ReadFile(file)
+		if err != nil {
+			return "", err
+		}
+		rel, _ := filepath.Rel(dir, file)
+		h.Write([]byte(filepath.ToSlash(rel)))
+		h.Write([]byte{0})
+		h.Write(content)
+		h.Write([]byte{0})
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+func fileSHA256(path string) (string, error) {
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(content)
+	return hex.EncodeToString(sum[:]), nil
+}
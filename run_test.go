@@ -0,0 +1,156 @@
+package terralib
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func newRunTest(t *testing.T) (*Terralib, *Run) {
+	dir, err := ioutil.TempDir("", "terralib")
+	if err != nil {
+		t.Fatal(err)
+	}
+	files := map[string]string{
+		"main.tf":             "resource \"aws_s3_bucket\" \"logs\" {}\n",
+		"modules/net/main.tf": "resource \"aws_vpc\" \"main\" {}\n",
+		"planfile":            "plan",
+	}
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		os.MkdirAll(filepath.Dir(path), 0755)
+		if err := ioutil.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	var plan ShowOutput
+	json.Unmarshal([]byte(showOutputPlanTest), &plan)
+	tf := &Terralib{ConfigPath: dir}
+	run := &Run{
+		PlanFile: "planfile",
+		Plan:     &plan,
+		Summary:  Summarize(&plan),
+	}
+	run.PlanSHA256, _ = fileSHA256(filepath.Join(dir, "planfile"))
+	run.ConfigFingerprint, _ = ConfigFingerprint(dir)
+	return tf, run
+}
+
+func TestSummarize(t *testing.T) {
+	var plan ShowOutput
+	json.Unmarshal([]byte(showOutputPlanTest), &plan)
+	got := Summarize(&plan)
+	expected := [4]int{1, 0, 1, 1}
+	if counts := [4]int{got.Create, got.Update, got.Delete, got.Replace}; counts != expected {
+		t.Errorf("Got: %v, Expected: %v", counts, expected)
+	}
+	if len(got.Changes) != 3 {
+		t.Errorf("Got %d changes, Expected: 3", len(got.Changes))
+	}
+}
+
+func TestApproveRun(t *testing.T) {
+	tf, run := newRunTest(t)
+	defer os.RemoveAll(tf.ConfigPath)
+	var summary ChangeSummary
+	err := tf.ApproveRun(run, ApproverFunc(func(s ChangeSummary) (bool, error) {
+		summary = s
+		return false, nil
+	}))
+	if !cmp.Equal(err, RunError{Reason: "The changes were rejected", Code: ErrRunRejected}) {
+		t.Errorf("Got: %+v, Expected: %s", err, ErrRunRejected)
+	}
+	if summary.Delete != 1 || run.Approved {
+		t.Errorf("Expected the rejected run to have the summary of the plan and not be approved")
+	}
+	_, err = tf.ApplyRun(run, nil)
+	if err == nil || err.Error() != ErrRunNotApproved {
+		t.Errorf("Got: %v, Expected: %s", err, ErrRunNotApproved)
+	}
+}
+
+func TestApplyRunChanged(t *testing.T) {
+	tf, run := newRunTest(t)
+	defer os.RemoveAll(tf.ConfigPath)
+	approve := ApproverFunc(func(ChangeSummary) (bool, error) {
+		return true, nil
+	})
+	if err := tf.ApproveRun(run, approve); err != nil {
+		t.Fatal(err)
+	}
+	ioutil.WriteFile(filepath.Join(tf.ConfigPath, "modules/net/main.tf"), []byte("\n"), 0644)
+	_, err := tf.ApplyRun(run, nil)
+	if err == nil || err.Error() != ErrConfigChanged {
+		t.Errorf("Got: %v, Expected: %s", err, ErrConfigChanged)
+	}
+	ioutil.WriteFile(filepath.Join(tf.ConfigPath, "planfile"), []byte("other plan"), 0644)
+	_, err = tf.ApplyRun(run, nil)
+	if err == nil || err.Error() != ErrPlanFileChanged {
+		t.Errorf("Got: %v, Expected: %s", err, ErrPlanFileChanged)
+	}
+}
+
+func TestApplyRunApprovedPlan(t *testing.T) {
+	tf, run := newRunTest(t)
+	defer os.RemoveAll(tf.ConfigPath)
+	// Approved by hand, without the plan being checked
+	run.Approved = true
+	if _, err := tf.ApplyRun(run, nil); err == nil || err.Error() != ErrRunNotApproved {
+		t.Errorf("Got: %v, Expected: %s", err, ErrRunNotApproved)
+	}
+	if err := tf.ApproveRun(run, ApproverFunc(func(ChangeSummary) (bool, error) {
+		return true, nil
+	})); err != nil {
+		t.Fatal(err)
+	}
+	// Another plan swapped in after the approval
+	ioutil.WriteFile(filepath.Join(tf.ConfigPath, "planfile"), []byte("other plan"), 0644)
+	run.PlanSHA256, _ = fileSHA256(filepath.Join(tf.ConfigPath, "planfile"))
+	if _, err := tf.ApplyRun(run, nil); err == nil || err.Error() != ErrPlanFileChanged {
+		t.Errorf("Got: %v, Expected: %s", err, ErrPlanFileChanged)
+	}
+}
+
+func TestPlanRunOptions(t *testing.T) {
+	tf, _ := newRunTest(t)
+	defer os.RemoveAll(tf.ConfigPath)
+	var args []string
+	tf.Executor = executorFunc(func(ctx context.Context, inv Invocation) (Result, error) {
+		if inv.Args[0] == CommandPlan {
+			args = inv.Args
+			return Result{Output: []byte("No changes.")}, nil
+		}
+		return Result{Output: []byte(showOutputPlanTest), Stdout: []byte(showOutputPlanTest)}, nil
+	})
+	options := make([]string, 2, 4)
+	copy(options, []string{"-out=other", "-no-color"})
+	if _, _, err := tf.PlanRun("planfile", options); err != nil {
+		t.Fatal(err)
+	}
+	expected := []string{"plan", "-input=false", "-no-color", "-out=planfile"}
+	if !cmp.Equal(args, expected) {
+		t.Errorf("Got: %v, Expected: %v", args, expected)
+	}
+	if backing := options[:4]; backing[2] != "" {
+		t.Errorf("Got: %v, Expected the options of the caller to be left alone", backing)
+	}
+	if got := withoutOutOption([]string{"-out", "other", "-lock=false"}); !cmp.Equal(got, []string{"-lock=false"}) {
+		t.Errorf("Got: %v, Expected: [-lock=false]", got)
+	}
+}
+
+func TestConfigFingerprintIgnoresTerraformDir(t *testing.T) {
+	tf, run := newRunTest(t)
+	defer os.RemoveAll(tf.ConfigPath)
+	os.MkdirAll(filepath.Join(tf.ConfigPath, ".terraform/modules"), 0755)
+	ioutil.WriteFile(filepath.Join(tf.ConfigPath, ".terraform/modules/main.tf"), []byte("\n"), 0644)
+	got, err := ConfigFingerprint(tf.ConfigPath)
+	if err != nil || got != run.ConfigFingerprint {
+		t.Errorf("Got: %s, Expected: %s", got, run.ConfigFingerprint)
+	}
+}
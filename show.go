@@ -2,19 +2,19 @@ package terralib
 
 import (
 	"encoding/json"
-	"os/exec"
-	"regexp"
-	"strings"
 )
 
 // Exported error codes
 const (
-	ErrShowDefault string = "errShowDefault"
+	ErrShowStateUnreadable string = "errShowStateUnreadable"
+	ErrShowDefault         string = "errShowDefault"
 )
 
-var showErrors = map[string]string{}
+var showClassifiers = []Classifier{
+	defaultClassifier(ErrShowDefault, CommandShow),
+}
 
-// ShowError represents an error on the Init command
+// ShowError represents an error on the Show command
 type ShowError struct {
 	Reason string
 	Code   string
@@ -24,57 +24,183 @@ func (e ShowError) Error() string {
 	return e.Code
 }
 
-// ShowOutput represents the output of the show command
+// Resource change actions
+const (
+	ActionNoOp    string = "no-op"
+	ActionCreate  string = "create"
+	ActionRead    string = "read"
+	ActionUpdate  string = "update"
+	ActionDelete  string = "delete"
+	ActionReplace string = "replace"
+)
+
+// ShowOutput represents the output of the show command on a plan file.
+// Planned and Changes hold PlannedValues and ResourceChanges decoded in Go
+// structs
 type ShowOutput struct {
-	FormatVersion    string      `json:"format_version,omitempty"`
-	TerraformVersion string      `json:"terraform_version,omitempty"`
-	PlannedValues    interface{} `json:"planned_values,omitempty"`
-	ResourceChanges  interface{} `json:"resource_changes,omitempty"`
-	Configuration    interface{} `json:"configuration,omitempty"`
+	FormatVersion    string            `json:"format_version,omitempty"`
+	TerraformVersion string            `json:"terraform_version,omitempty"`
+	PlannedValues    interface{}       `json:"planned_values,omitempty"`
+	ResourceChanges  interface{}       `json:"resource_changes,omitempty"`
+	OutputChanges    map[string]Change `json:"output_changes,omitempty"`
+	Configuration    interface{}       `json:"configuration,omitempty"`
+	Planned          *Values           `json:"-"`
+	Changes          []ResourceChange  `json:"-"`
+}
+
+// UnmarshalJSON decodes the plan, filling Planned and Changes too
+func (s *ShowOutput) UnmarshalJSON(data []byte) error {
+	type showOutput ShowOutput
+	var out showOutput
+	if err := json.Unmarshal(data, &out); err != nil {
+		return err
+	}
+	var typed struct {
+		PlannedValues   *Values          `json:"planned_values"`
+		ResourceChanges []ResourceChange `json:"resource_changes"`
+	}
+	if err := json.Unmarshal(data, &typed); err != nil {
+		return err
+	}
+	out.Planned = typed.PlannedValues
+	out.Changes = typed.ResourceChanges
+	*s = ShowOutput(out)
+	return nil
+}
+
+// Values represents the values of the outputs and resources of a configuration
+type Values struct {
+	Outputs    map[string]OutputValue `json:"outputs,omitempty"`
+	RootModule Module                 `json:"root_module"`
+}
+
+// OutputValue represents the value of an output
+type OutputValue struct {
+	Sensitive bool        `json:"sensitive"`
+	Value     interface{} `json:"value,omitempty"`
+}
+
+// Module represents the resources of a module and its child modules
+type Module struct {
+	Address      string     `json:"address,omitempty"`
+	Resources    []Resource `json:"resources,omitempty"`
+	ChildModules []Module   `json:"child_modules,omitempty"`
+}
+
+// Resource represents the values of a resource instance
+type Resource struct {
+	Address         string                 `json:"address"`
+	Mode            string                 `json:"mode"`
+	Type            string                 `json:"type"`
+	Name            string                 `json:"name"`
+	Index           interface{}            `json:"index,omitempty"`
+	ProviderName    string                 `json:"provider_name"`
+	SchemaVersion   int                    `json:"schema_version"`
+	Values          map[string]interface{} `json:"values,omitempty"`
+	SensitiveValues interface{}            `json:"sensitive_values,omitempty"`
+	DependsOn       []string               `json:"depends_on,omitempty"`
+	Tainted         bool                   `json:"tainted,omitempty"`
+	DeposedKey      string                 `json:"deposed_key,omitempty"`
+}
+
+// ResourceChange represents the planned change of a resource instance
+type ResourceChange struct {
+	Address       string      `json:"address"`
+	ModuleAddress string      `json:"module_address,omitempty"`
+	Mode          string      `json:"mode"`
+	Type          string      `json:"type"`
+	Name          string      `json:"name"`
+	Index         interface{} `json:"index,omitempty"`
+	ProviderName  string      `json:"provider_name"`
+	Change        Change      `json:"change"`
+	ActionReason  string      `json:"action_reason,omitempty"`
+}
+
+// Change represents the values of a resource or output before and after a change
+type Change struct {
+	Actions         []string        `json:"actions"`
+	Before          interface{}     `json:"before"`
+	After           interface{}     `json:"after"`
+	AfterUnknown    interface{}     `json:"after_unknown,omitempty"`
+	BeforeSensitive interface{}     `json:"before_sensitive,omitempty"`
+	AfterSensitive  interface{}     `json:"after_sensitive,omitempty"`
+	ReplacePaths    [][]interface{} `json:"replace_paths,omitempty"`
+}
+
+// Resources returns the resources of every module
+func (v *Values) Resources() []Resource {
+	if v == nil {
+		return nil
+	}
+	return v.RootModule.resources()
+}
+
+func (m Module) resources() []Resource {
+	resources := append([]Resource(nil), m.Resources...)
+	for _, child := range m.ChildModules {
+		resources = append(resources, child.resources()...)
+	}
+	return resources
+}
+
+// Action returns the action of the change, ActionReplace when the resource
+// is deleted and created again
+func (c Change) Action() string {
+	switch len(c.Actions) {
+	case 0:
+		return ActionNoOp
+	case 1:
+		return c.Actions[0]
+	}
+	return ActionReplace
 }
 
 // Show executes the 'terraform show' command
 func (t *Terralib) Show(path string) (ShowOutput, error) {
+	showOutput, _, err := t.show(path)
+	return showOutput, err
+}
+
+// show executes the 'terraform show' command and returns the plan read
+// from its standard output, which is not checked, along with the output
+func (t *Terralib) show(path string) (showOutput ShowOutput, stdout []byte, err error) {
 	options := []string{
 		"-no-color",
 		"-json",
 		path,
 	}
-	cmdString := formatCommand("show", options)
-	cmd := exec.Command("sh", "-c", cmdString)
-	cmd.Dir = t.ConfigPath
-	stdOutputError, _ := cmd.CombinedOutput()
-	showError := findShowError(stdOutputError)
-	// Unmarshal data
-	var output ShowOutput
-	json.Unmarshal([]byte(stdOutputError), &output)
-	return output, showError
+	if err := t.beforeCommand(CommandShow, options); err != nil {
+		return ShowOutput{}, nil, err
+	}
+	defer func() {
+		t.afterCommand(CommandShow, options, showOutput, err)
+	}()
+	result, match, _ := t.executeResult(CommandShow, options, nil)
+	showError := showErrorFrom(match)
+	stdout = resultStdout(result)
+	json.Unmarshal(stdout, &showOutput)
+	return showOutput, stdout, showError
+}
+
+// resultStdout returns the standard output of a result, or its combined
+// output when the executor does not keep them apart
+func resultStdout(result Result) []byte {
+	if result.Stdout == nil {
+		return result.Output
+	}
+	return result.Stdout
 }
 
 func findShowError(output []byte) error {
-	var showError ShowError
-	for k, v := range showErrors {
-		r := regexp.MustCompile(v)
-		line := r.Find(output)
-		if line != nil {
-			showError = ShowError{
-				Reason: string(line),
-				Code:   k,
-			}
-			return showError
-		}
+	return showErrorFrom(DefaultClassifiers.Classify(CommandShow, "", output))
+}
+
+func showErrorFrom(match *Match) error {
+	if match == nil {
+		return nil
 	}
-	// Get a default error or else return no error
-	r := regexp.MustCompile("Error: (.*).")
-	line := r.Find(output)
-	if line != nil {
-		reason := strings.Split(string(line), "Error: ")
-		if reason != nil {
-			return PlanError{
-				Reason: strings.TrimSuffix(reason[1], "."),
-				Code:   ErrShowDefault,
-			}
-		}
+	return ShowError{
+		Reason: match.Reason,
+		Code:   match.Code,
 	}
-	return nil
 }
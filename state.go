@@ -0,0 +1,46 @@
+package terralib
+
+import (
+	"encoding/json"
+)
+
+// State represents the outputs and resources of a state
+type State struct {
+	FormatVersion    string  `json:"format_version,omitempty"`
+	TerraformVersion string  `json:"terraform_version,omitempty"`
+	Lineage          string  `json:"lineage,omitempty"`
+	Serial           uint64  `json:"serial,omitempty"`
+	Values           *Values `json:"values,omitempty"`
+}
+
+// Resources returns the resources of every module in the state
+func (s *State) Resources() []Resource {
+	return s.Values.Resources()
+}
+
+// ShowState executes the 'terraform show' command on the current state. It
+// fails with ErrShowStateUnreadable when the JSON printed by terraform cannot
+// be read
+func (t *Terralib) ShowState() (state State, err error) {
+	options := []string{
+		"-no-color",
+		"-json",
+	}
+	if err := t.beforeCommand(CommandShow, options); err != nil {
+		return State{}, err
+	}
+	defer func() {
+		t.afterCommand(CommandShow, options, state, err)
+	}()
+	result, match, _ := t.executeResult(CommandShow, options, nil)
+	if match != nil {
+		return State{}, showErrorFrom(match)
+	}
+	if err := json.Unmarshal(resultStdout(result), &state); err != nil {
+		return State{}, ShowError{
+			Reason: "The state could not be read: " + err.Error(),
+			Code:   ErrShowStateUnreadable,
+		}
+	}
+	return state, nil
+}
@@ -0,0 +1,301 @@
+package terralib
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Exported error codes
+const (
+	ErrStateAddressNotFound string = "errStateAddressNotFound"
+	ErrStateAddressExists   string = "errStateAddressExists"
+	ErrStateInvalidMove     string = "errStateInvalidMove"
+)
+
+// stateTarget represents the address of a module, a resource or a resource
+// instance in a state file
+type stateTarget struct {
+	module   string
+	isModule bool
+	address  ResourceAddress
+}
+
+func parseStateTarget(address string) (stateTarget, error) {
+	if module, err := ParseModuleAddress(address); err == nil {
+		return stateTarget{
+			module:   ResourceAddress{Module: module}.ModuleAddress(),
+			isModule: true,
+		}, nil
+	}
+	a, err := ParseResourceAddress(address)
+	if err != nil {
+		return stateTarget{}, err
+	}
+	return stateTarget{
+		module:  a.ModuleAddress(),
+		address: a,
+	}, nil
+}
+
+// isInstance tells whether the target is a single instance of a resource
+func (t stateTarget) isInstance() bool {
+	return !t.isModule && t.address.Key != nil
+}
+
+// matchesResource tells whether a resource is in the target. A module address
+// without key, like module.net, matches every instance of the module
+func (t stateTarget) matchesResource(r StateFileResource) bool {
+	if t.isModule {
+		if !strings.HasPrefix(r.Module, t.module) {
+			return false
+		}
+		rest := r.Module[len(t.module):]
+		return rest == "" || rest[0] == '.' || rest[0] == '['
+	}
+	return r.Module == t.module && r.Mode == t.address.Mode && r.Type == t.address.Type && r.Name == t.address.Name
+}
+
+func (t stateTarget) matchesInstance(instance StateFileInstance) bool {
+	return !t.isInstance() || instanceKey(instance) == t.address.Key
+}
+
+// instanceKey returns the index key of an instance as in ResourceAddress,
+// an int or a string
+func instanceKey(instance StateFileInstance) interface{} {
+	if n, ok := instance.IndexKey.(float64); ok {
+		return int(n)
+	}
+	return instance.IndexKey
+}
+
+// stateKey returns a ResourceAddress key as read from a state file
+func stateKey(key interface{}) interface{} {
+	if n, ok := key.(int); ok {
+		return float64(n)
+	}
+	return key
+}
+
+// eachMode returns the each mode of a resource whose instances have the key
+func eachMode(key interface{}) string {
+	switch key.(type) {
+	case int:
+		return "list"
+	case string:
+		return "map"
+	}
+	return ""
+}
+
+// Move renames a module, a resource or a resource instance in the state, like
+// 'terraform state mv'
+func (f *StateFile) Move(from string, to string) error {
+	return f.MoveTo(f, from, to)
+}
+
+// MoveTo moves a module, a resource or a resource instance to another state
+// file, with the address to. Nothing changes when it fails
+func (f *StateFile) MoveTo(dest *StateFile, from string, to string) error {
+	source, err := parseStateTarget(from)
+	if err != nil {
+		return err
+	}
+	target, err := parseStateTarget(to)
+	if err != nil {
+		return err
+	}
+	if source.isModule != target.isModule || (!source.isInstance() && target.isInstance()) {
+		return StateError{
+			Reason: fmt.Sprintf("Cannot move %s to %s: the addresses are of different kinds", from, to),
+			Code:   ErrStateInvalidMove,
+		}
+	}
+	moved, remaining := f.extract(source)
+	if len(moved) == 0 {
+		return StateError{
+			Reason: "No state found at " + from,
+			Code:   ErrStateAddressNotFound,
+		}
+	}
+	for i := range moved {
+		if err := rename(&moved[i], source, target); err != nil {
+			return err
+		}
+	}
+	resources := dest.Resources
+	if dest == f {
+		resources = remaining
+	}
+	merged, err := mergeResources(resources, moved, source.isInstance())
+	if err != nil {
+		return err
+	}
+	if dest != f {
+		f.Resources = remaining
+		if dest.TerraformVersion == "" {
+			dest.TerraformVersion = f.TerraformVersion
+		}
+	}
+	dest.Resources = merged
+	return nil
+}
+
+// Remove removes a module, a resource or a resource instance from the state,
+// like 'terraform state rm', and returns the number of instances removed
+func (f *StateFile) Remove(address string) (int, error) {
+	target, err := parseStateTarget(address)
+	if err != nil {
+		return 0, err
+	}
+	removed, remaining := f.extract(target)
+	if len(removed) == 0 {
+		return 0, StateError{
+			Reason: "No state found at " + address,
+			Code:   ErrStateAddressNotFound,
+		}
+	}
+	count := 0
+	for _, r := range removed {
+		count += len(r.Instances)
+	}
+	f.Resources = remaining
+	return count, nil
+}
+
+// ReplaceProvider replaces the provider of the resources using the provider
+// with source address from, like 'terraform state replace-provider', and
+// returns the number of resources changed. Source addresses may leave out the
+// registry hostname. The provider.aws addresses of terraform 0.12 are those of
+// the legacy provider -/aws, as terraform 0.13 reads them
+func (f *StateFile) ReplaceProvider(from string, to string) int {
+	from = fullProviderSource(from)
+	old := `provider["` + from + `"]`
+	new := `provider["` + fullProviderSource(to) + `"]`
+	var legacy string
+	if strings.HasPrefix(from, defaultRegistry+"/-/") {
+		legacy = "provider." + strings.TrimPrefix(from, defaultRegistry+"/-/")
+	}
+	count := 0
+	for i, r := range f.Resources {
+		switch {
+		case strings.Contains(r.Provider, old):
+			f.Resources[i].Provider = strings.Replace(r.Provider, old, new, 1)
+		case legacy != "" && isLegacyProvider(r.Provider, legacy):
+			f.Resources[i].Provider = strings.Replace(r.Provider, legacy, new, 1)
+		default:
+			continue
+		}
+		count++
+	}
+	return count
+}
+
+// isLegacyProvider tells whether a provider configuration address of terraform
+// 0.12, like module.net.provider.aws.west, is one of the provider legacy
+func isLegacyProvider(provider string, legacy string) bool {
+	i := strings.Index(provider, legacy)
+	if i < 0 || (i > 0 && provider[i-1] != '.') {
+		return false
+	}
+	rest := provider[i+len(legacy):]
+	return rest == "" || rest[0] == '.'
+}
+
+// extract splits the resources of the state in copies of the ones matching the
+// target, with only the matching instances, and the rest
+func (f *StateFile) extract(target stateTarget) ([]StateFileResource, []StateFileResource) {
+	var matched, remaining []StateFileResource
+	for _, r := range f.Resources {
+		if !target.matchesResource(r) {
+			remaining = append(remaining, r)
+			continue
+		}
+		in, out := r, r
+		in.Instances, out.Instances = nil, nil
+		for _, instance := range r.Instances {
+			if target.matchesInstance(instance) {
+				in.Instances = append(in.Instances, instance)
+			} else {
+				out.Instances = append(out.Instances, instance)
+			}
+		}
+		if len(in.Instances) > 0 {
+			matched = append(matched, in)
+		}
+		if len(out.Instances) > 0 {
+			remaining = append(remaining, out)
+		}
+	}
+	return matched, remaining
+}
+
+// rename gives a resource moved from source the address of target
+func rename(r *StateFileResource, source stateTarget, target stateTarget) error {
+	if source.isModule {
+		rest := strings.TrimPrefix(r.Module, source.module)
+		if strings.HasPrefix(rest, "[") && strings.HasSuffix(target.module, "]") {
+			return StateError{
+				Reason: fmt.Sprintf("Cannot move the instances of %s to the module instance %s",
+					source.module, target.module),
+				Code: ErrStateInvalidMove,
+			}
+		}
+		r.Module = target.module + rest
+		return nil
+	}
+	if r.Mode != target.address.Mode || r.Type != target.address.Type {
+		return StateError{
+			Reason: fmt.Sprintf("Cannot move %s to %s: the resource types do not match",
+				source.address, target.address),
+			Code: ErrStateInvalidMove,
+		}
+	}
+	r.Module = target.module
+	r.Name = target.address.Name
+	if source.isInstance() {
+		r.EachMode = eachMode(target.address.Key)
+		for i := range r.Instances {
+			r.Instances[i].IndexKey = stateKey(target.address.Key)
+		}
+	}
+	return nil
+}
+
+// mergeResources adds resources to a list of resources. Instances are added
+// to the resource with the same address when mergeInstances is set, unless
+// one has the same key
+func mergeResources(resources []StateFileResource, added []StateFileResource, mergeInstances bool) ([]StateFileResource, error) {
+	merged := append([]StateFileResource(nil), resources...)
+	for _, r := range added {
+		i := 0
+		for i < len(merged) && merged[i].Address() != r.Address() {
+			i++
+		}
+		if i == len(merged) {
+			merged = append(merged, r)
+			continue
+		}
+		if !mergeInstances {
+			return nil, StateError{
+				Reason: "Resource " + r.Address() + " already exists in the state",
+				Code:   ErrStateAddressExists,
+			}
+		}
+		for _, instance := range r.Instances {
+			for _, existing := range merged[i].Instances {
+				if existing.Deposed == "" && instance.Deposed == "" && instanceKey(existing) == instanceKey(instance) {
+					return nil, StateError{
+						Reason: "Resource instance " + r.InstanceAddress(instance) + " already exists in the state",
+						Code:   ErrStateAddressExists,
+					}
+				}
+			}
+		}
+		instances := append([]StateFileInstance(nil), merged[i].Instances...)
+		merged[i].Instances = append(instances, r.Instances...)
+		if merged[i].EachMode == "" {
+			merged[i].EachMode = r.EachMode
+		}
+	}
+	return merged, nil
+}
@@ -0,0 +1,242 @@
+package terralib
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func loadModulesStateFile(t *testing.T) *StateFile {
+	f, err := LoadStateFile("testdata/state/modules.tfstate")
+	if err != nil {
+		t.Fatalf("Got error: %v", err)
+	}
+	return f
+}
+
+// instanceAddresses returns the address of every instance in the state, with
+// the deposed key of deposed objects
+func instanceAddresses(f *StateFile) []string {
+	var addresses []string
+	for _, r := range f.Resources {
+		for _, instance := range r.Instances {
+			address := r.InstanceAddress(instance)
+			if instance.Deposed != "" {
+				address += " (deposed " + instance.Deposed + ")"
+			}
+			addresses = append(addresses, address)
+		}
+	}
+	return addresses
+}
+
+func TestStateFileMove(t *testing.T) {
+	tests := []struct {
+		from     string
+		to       string
+		expected []string
+	}{
+		{
+			from: "aws_db_instance.main",
+			to:   "aws_db_instance.primary",
+			expected: []string{
+				"data.aws_caller_identity.current",
+				"module.net.aws_subnet.private[0]",
+				"module.net.aws_subnet.private[1]",
+				`module.net.module.flow_logs["eu"].aws_flow_log.this["vpc"]`,
+				"aws_db_instance.primary",
+				"aws_db_instance.primary (deposed 00000001)",
+			},
+		},
+		{
+			from: "module.net.aws_subnet.private[1]",
+			to:   "aws_subnet.legacy",
+			expected: []string{
+				"data.aws_caller_identity.current",
+				"aws_db_instance.main",
+				"aws_db_instance.main (deposed 00000001)",
+				"module.net.aws_subnet.private[0]",
+				`module.net.module.flow_logs["eu"].aws_flow_log.this["vpc"]`,
+				"aws_subnet.legacy",
+			},
+		},
+		{
+			from: "module.net.aws_subnet.private[1]",
+			to:   "module.net.aws_subnet.private[2]",
+			expected: []string{
+				"data.aws_caller_identity.current",
+				"aws_db_instance.main",
+				"aws_db_instance.main (deposed 00000001)",
+				"module.net.aws_subnet.private[0]",
+				"module.net.aws_subnet.private[2]",
+				`module.net.module.flow_logs["eu"].aws_flow_log.this["vpc"]`,
+			},
+		},
+		{
+			from: "module.net.module.flow_logs",
+			to:   "module.net.module.logs",
+			expected: []string{
+				"data.aws_caller_identity.current",
+				"aws_db_instance.main",
+				"aws_db_instance.main (deposed 00000001)",
+				"module.net.aws_subnet.private[0]",
+				"module.net.aws_subnet.private[1]",
+				`module.net.module.logs["eu"].aws_flow_log.this["vpc"]`,
+			},
+		},
+		{
+			from: "module.net",
+			to:   `module.network["eu"]`,
+			expected: []string{
+				"data.aws_caller_identity.current",
+				"aws_db_instance.main",
+				"aws_db_instance.main (deposed 00000001)",
+				`module.network["eu"].aws_subnet.private[0]`,
+				`module.network["eu"].aws_subnet.private[1]`,
+				`module.network["eu"].module.flow_logs["eu"].aws_flow_log.this["vpc"]`,
+			},
+		},
+	}
+	for _, test := range tests {
+		f := loadModulesStateFile(t)
+		if err := f.Move(test.from, test.to); err != nil {
+			t.Errorf("%s: Got error: %v", test.from, err)
+			continue
+		}
+		if got := instanceAddresses(f); !cmp.Equal(got, test.expected) {
+			t.Errorf("%s: Got: %v, Expected: %v", test.from, got, test.expected)
+		}
+	}
+}
+
+func TestStateFileMoveErrors(t *testing.T) {
+	tests := []struct {
+		from string
+		to   string
+		code string
+	}{
+		{"aws_db_instance.replica", "aws_db_instance.main", ErrStateAddressNotFound},
+		{"aws_db_instance.main", "aws_rds_cluster.main", ErrStateInvalidMove},
+		{"module.net", "aws_db_instance.net", ErrStateInvalidMove},
+		{"aws_db_instance.main", "module.net.aws_subnet.private", ErrStateInvalidMove},
+		{"module.net.aws_subnet.private[1]", "module.net.aws_subnet.private[0]", ErrStateAddressExists},
+		{"module.net.aws_subnet.private", "aws_db_instance.main", ErrStateInvalidMove},
+		{"module.net.module.flow_logs", `module.net.module.logs["us"]`, ErrStateInvalidMove},
+	}
+	for _, test := range tests {
+		f := loadModulesStateFile(t)
+		before := f.Bytes()
+		err := f.Move(test.from, test.to)
+		if stateErr, ok := err.(StateError); !ok || stateErr.Code != test.code {
+			t.Errorf("%s -> %s: Got: %v, Expected: %s", test.from, test.to, err, test.code)
+		}
+		if after := f.Bytes(); string(after) != string(before) {
+			t.Errorf("%s -> %s: The state changed", test.from, test.to)
+		}
+	}
+}
+
+func TestStateFileMoveTo(t *testing.T) {
+	f := loadModulesStateFile(t)
+	dest, err := NewStateFile()
+	if err != nil {
+		t.Fatalf("Got error: %v", err)
+	}
+	if err := f.MoveTo(dest, "module.net", "module.net"); err != nil {
+		t.Fatalf("Got error: %v", err)
+	}
+	expected := []string{
+		"data.aws_caller_identity.current",
+		"aws_db_instance.main",
+		"aws_db_instance.main (deposed 00000001)",
+	}
+	if got := instanceAddresses(f); !cmp.Equal(got, expected) {
+		t.Errorf("Got source: %v, Expected: %v", got, expected)
+	}
+	expected = []string{
+		"module.net.aws_subnet.private[0]",
+		"module.net.aws_subnet.private[1]",
+		`module.net.module.flow_logs["eu"].aws_flow_log.this["vpc"]`,
+	}
+	if got := instanceAddresses(dest); !cmp.Equal(got, expected) {
+		t.Errorf("Got destination: %v, Expected: %v", got, expected)
+	}
+	if dest.TerraformVersion != "1.5.7" || dest.Lineage == "" || dest.Lineage == f.Lineage {
+		t.Errorf("Got destination version %s and lineage %s", dest.TerraformVersion, dest.Lineage)
+	}
+}
+
+func TestStateFileRemove(t *testing.T) {
+	tests := []struct {
+		address string
+		removed int
+	}{
+		{"aws_db_instance.main", 2},
+		{"module.net.aws_subnet.private[0]", 1},
+		{"module.net", 3},
+		{"module.net.module.flow_logs", 1},
+		{`module.net.module.flow_logs["eu"]`, 1},
+		{"data.aws_caller_identity.current", 1},
+	}
+	for _, test := range tests {
+		f := loadModulesStateFile(t)
+		removed, err := f.Remove(test.address)
+		if err != nil || removed != test.removed {
+			t.Errorf("%s: Got: %d, %v, Expected: %d", test.address, removed, err, test.removed)
+		}
+		for _, address := range instanceAddresses(f) {
+			if address == test.address {
+				t.Errorf("%s: Still in the state", test.address)
+			}
+		}
+	}
+	f := loadModulesStateFile(t)
+	for _, address := range []string{"module.net.aws_subnet.private[5]", `module.net.module.flow_logs["us"]`, "module.ne"} {
+		if _, err := f.Remove(address); err == nil || err.(StateError).Code != ErrStateAddressNotFound {
+			t.Errorf("%s: Got: %v, Expected: %s", address, err, ErrStateAddressNotFound)
+		}
+	}
+}
+
+func TestStateFileReplaceProvider(t *testing.T) {
+	f := loadModulesStateFile(t)
+	if got := f.ReplaceProvider("hashicorp/aws", "registry.acme.com/acme/aws"); got != 3 {
+		t.Errorf("Got: %d resources, Expected: 3", got)
+	}
+	expected := []string{
+		`provider["registry.acme.com/acme/aws"]`,
+		`provider["registry.acme.com/acme/aws"]`,
+		`provider["registry.acme.com/acme/aws"].west`,
+		"module.net.provider.aws",
+	}
+	var got []string
+	for _, r := range f.Resources {
+		got = append(got, r.Provider)
+	}
+	if !cmp.Equal(got, expected) {
+		t.Errorf("Got: %v, Expected: %v", got, expected)
+	}
+}
+
+func TestStateFileReplaceLegacyProvider(t *testing.T) {
+	f := loadModulesStateFile(t)
+	if got := f.ReplaceProvider("-/aws", "hashicorp/aws"); got != 1 {
+		t.Errorf("Got: %d resources, Expected: 1", got)
+	}
+	expected := []string{
+		`provider["registry.terraform.io/hashicorp/aws"]`,
+		`provider["registry.terraform.io/hashicorp/aws"]`,
+		`provider["registry.terraform.io/hashicorp/aws"].west`,
+		`module.net.provider["registry.terraform.io/hashicorp/aws"]`,
+	}
+	var got []string
+	for _, r := range f.Resources {
+		got = append(got, r.Provider)
+	}
+	if !cmp.Equal(got, expected) {
+		t.Errorf("Got: %v, Expected: %v", got, expected)
+	}
+	if !isLegacyProvider("provider.aws.west", "provider.aws") || isLegacyProvider("provider.awscc", "provider.aws") {
+		t.Errorf("Got the wrong legacy provider match")
+	}
+}
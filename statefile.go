@@ -0,0 +1,413 @@
+package terralib
+
+import (
+	"crypto/rand"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// StateFileName is the name of the state file of the local backend
+const StateFileName string = "terraform.tfstate"
+
+// stateFileVersion is the only state format version read, the one written by
+// terraform 0.12+
+const stateFileVersion = 4
+
+// StateFile represents a state file as written by terraform, format version 4.
+// Attributes and output values are kept as they are in the file
+type StateFile struct {
+	Version          int                        `json:"version"`
+	TerraformVersion string                     `json:"terraform_version"`
+	Serial           uint64                     `json:"serial"`
+	Lineage          string                     `json:"lineage"`
+	Outputs          map[string]StateFileOutput `json:"outputs"`
+	Resources        []StateFileResource        `json:"resources"`
+	CheckResults     json.RawMessage            `json:"check_results,omitempty"`
+}
+
+// StateFileOutput represents an output value in a state file
+type StateFileOutput struct {
+	Value     json.RawMessage `json:"value"`
+	Type      json.RawMessage `json:"type,omitempty"`
+	Sensitive bool            `json:"sensitive,omitempty"`
+}
+
+// StateFileResource represents a resource in a state file. Module is the
+// address of its module, empty for the root module, and EachMode is "list" or
+// "map" when it uses count or for_each
+type StateFileResource struct {
+	Module    string              `json:"module,omitempty"`
+	Mode      string              `json:"mode"`
+	Type      string              `json:"type"`
+	Name      string              `json:"name"`
+	EachMode  string              `json:"each,omitempty"`
+	Provider  string              `json:"provider"`
+	Instances []StateFileInstance `json:"instances"`
+}
+
+// StateFileInstance represents an instance of a resource in a state file.
+// IndexKey is a number or a string when the resource uses count or for_each,
+// and Deposed is set for the objects waiting to be destroyed after a replace
+type StateFileInstance struct {
+	IndexKey            interface{}       `json:"index_key,omitempty"`
+	Status              string            `json:"status,omitempty"`
+	Deposed             string            `json:"deposed,omitempty"`
+	SchemaVersion       int               `json:"schema_version"`
+	Attributes          json.RawMessage   `json:"attributes,omitempty"`
+	AttributesFlat      map[string]string `json:"attributes_flat,omitempty"`
+	SensitiveAttributes json.RawMessage   `json:"sensitive_attributes,omitempty"`
+	Private             string            `json:"private,omitempty"`
+	Dependencies        []string          `json:"dependencies,omitempty"`
+	// DependsOn is written by terraform 0.12 instead of Dependencies
+	DependsOn           []string `json:"depends_on,omitempty"`
+	CreateBeforeDestroy bool     `json:"create_before_destroy,omitempty"`
+}
+
+// sensitiveStep represents a step of the path of a sensitive attribute,
+// {"type": "get_attr", "value": "password"} or {"type": "index", "value":
+// {"value": 0, "type": "number"}}
+type sensitiveStep struct {
+	Type  string          `json:"type"`
+	Value json.RawMessage `json:"value"`
+}
+
+// LoadStateFile reads a state file, like terraform.tfstate or one of its
+// backups
+func LoadStateFile(path string) (*StateFile, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	state, err := ParseStateFile(data)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+	return state, nil
+}
+
+// NewStateFile returns an empty state with a new lineage
+func NewStateFile() (*StateFile, error) {
+	uuid := make([]byte, 16)
+	if _, err := rand.Read(uuid); err != nil {
+		return nil, err
+	}
+	uuid[6] = uuid[6]&0x0f | 0x40
+	uuid[8] = uuid[8]&0x3f | 0x80
+	return &StateFile{
+		Version: stateFileVersion,
+		Lineage: fmt.Sprintf("%x-%x-%x-%x-%x", uuid[0:4], uuid[4:6], uuid[6:8], uuid[8:10], uuid[10:]),
+	}, nil
+}
+
+// ReadStateFile reads a state file from r
+func ReadStateFile(r io.Reader) (*StateFile, error) {
+	data, err := ioutil.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	return ParseStateFile(data)
+}
+
+// ParseStateFile parses the contents of a state file. Only format version 4
+// is supported
+func ParseStateFile(data []byte) (*StateFile, error) {
+	var version struct {
+		Version int `json:"version"`
+	}
+	if err := json.Unmarshal(data, &version); err != nil {
+		return nil, err
+	}
+	if version.Version != stateFileVersion {
+		return nil, fmt.Errorf("Unsupported state format version %d", version.Version)
+	}
+	var state StateFile
+	if err := json.Unmarshal(data, &state); err != nil {
+		return nil, err
+	}
+	return &state, nil
+}
+
+// Bytes returns the state file in the layout written by terraform, with its
+// resources sorted by module, mode, type and name
+func (f *StateFile) Bytes() []byte {
+	out := *f
+	out.Version = stateFileVersion
+	if out.Outputs == nil {
+		out.Outputs = map[string]StateFileOutput{}
+	}
+	out.Resources = append([]StateFileResource{}, f.Resources...)
+	sort.SliceStable(out.Resources, func(i, j int) bool {
+		a, b := out.Resources[i], out.Resources[j]
+		if a.Module != b.Module {
+			return a.Module < b.Module
+		}
+		if a.Mode != b.Mode {
+			return a.Mode < b.Mode
+		}
+		if a.Type != b.Type {
+			return a.Type < b.Type
+		}
+		return a.Name < b.Name
+	})
+	data, _ := json.MarshalIndent(out, "", "  ")
+	return append(data, '\n')
+}
+
+// Save writes the state file with its serial incremented, after checking it
+// can replace the state at path like CheckStatePush. The state it replaces is
+// kept in a backup next to it, terraform.tfstate.<unix time>.backup, with a
+// counter before .backup when there is one already. Its path is returned
+func (f *StateFile) Save(path string) (string, error) {
+	next := *f
+	next.Serial++
+	var backup string
+	current, err := LoadStateFile(path)
+	switch {
+	case os.IsNotExist(err):
+	case err != nil:
+		return "", err
+	default:
+		if err := CheckStatePush(current, &next); err != nil {
+			return "", err
+		}
+		backup, err = writeBackup(path, current.Bytes())
+		if err != nil {
+			return "", err
+		}
+	}
+	tmp, err := ioutil.TempFile(filepath.Dir(path), filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return "", err
+	}
+	defer os.Remove(tmp.Name())
+	_, err = tmp.Write(next.Bytes())
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return "", err
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return "", err
+	}
+	f.Serial = next.Serial
+	return backup, nil
+}
+
+// writeBackup writes data to a new backup file of the state at path, never
+// replacing an existing one
+func writeBackup(path string, data []byte) (string, error) {
+	prefix := path + "." + strconv.FormatInt(time.Now().Unix(), 10)
+	for i := 0; ; i++ {
+		backup := prefix + ".backup"
+		if i > 0 {
+			backup = prefix + "." + strconv.Itoa(i) + ".backup"
+		}
+		f, err := os.OpenFile(backup, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
+		if os.IsExist(err) {
+			continue
+		}
+		if err != nil {
+			return "", err
+		}
+		_, err = f.Write(data)
+		if closeErr := f.Close(); err == nil {
+			err = closeErr
+		}
+		return backup, err
+	}
+}
+
+// State returns the state in the model of ShowState, with a resource for
+// every instance. Deposed objects are resources with a DeposedKey
+func (f *StateFile) State() (State, error) {
+	state := State{
+		TerraformVersion: f.TerraformVersion,
+		Lineage:          f.Lineage,
+		Serial:           f.Serial,
+		Values:           &Values{},
+	}
+	if len(f.Outputs) > 0 {
+		state.Values.Outputs = map[string]OutputValue{}
+	}
+	for name, output := range f.Outputs {
+		var value interface{}
+		if err := json.Unmarshal(output.Value, &value); err != nil {
+			return state, fmt.Errorf("Output %s: %v", name, err)
+		}
+		state.Values.Outputs[name] = OutputValue{
+			Sensitive: output.Sensitive,
+			Value:     value,
+		}
+	}
+	resources := map[string][]Resource{"": nil}
+	for _, r := range f.Resources {
+		for _, instance := range r.Instances {
+			resource, err := r.resource(instance)
+			if err != nil {
+				return state, err
+			}
+			resources[r.Module] = append(resources[r.Module], resource)
+		}
+		for module := r.Module; module != ""; module = parentModule(module) {
+			if _, ok := resources[module]; !ok {
+				resources[module] = nil
+			}
+		}
+	}
+	state.Values.RootModule = stateModule(resources, "")
+	return state, nil
+}
+
+// Address returns the address of the resource, without instance key
+func (r StateFileResource) Address() string {
+	address := r.Type + "." + r.Name
+	if r.Mode == ModeData {
+		address = "data." + address
+	}
+	if r.Module != "" {
+		address = r.Module + "." + address
+	}
+	return address
+}
+
+// InstanceAddress returns the address of an instance of the resource
+func (r StateFileResource) InstanceAddress(instance StateFileInstance) string {
+	key := instance.IndexKey
+	if n, ok := key.(float64); ok {
+		key = int(n)
+	}
+	return r.Address() + formatKey(key)
+}
+
+func (r StateFileResource) resource(instance StateFileInstance) (Resource, error) {
+	address := r.InstanceAddress(instance)
+	resource := Resource{
+		Address:       address,
+		Mode:          r.Mode,
+		Type:          r.Type,
+		Name:          r.Name,
+		Index:         instance.IndexKey,
+		ProviderName:  stateProviderName(r.Provider),
+		SchemaVersion: instance.SchemaVersion,
+		DependsOn:     instance.Dependencies,
+		Tainted:       instance.Status == "tainted",
+		DeposedKey:    instance.Deposed,
+	}
+	if resource.DependsOn == nil {
+		resource.DependsOn = instance.DependsOn
+	}
+	if len(instance.Attributes) > 0 {
+		if err := json.Unmarshal(instance.Attributes, &resource.Values); err != nil {
+			return resource, fmt.Errorf("Resource %s: %v", address, err)
+		}
+	}
+	var paths [][]sensitiveStep
+	if len(instance.SensitiveAttributes) > 0 {
+		if err := json.Unmarshal(instance.SensitiveAttributes, &paths); err != nil {
+			return resource, fmt.Errorf("Resource %s: %v", address, err)
+		}
+	}
+	var sensitive interface{} = map[string]interface{}{}
+	for _, path := range paths {
+		sensitive = markSensitive(sensitive, path)
+	}
+	resource.SensitiveValues = sensitive
+	return resource, nil
+}
+
+// stateModule returns the module with the given address and its child
+// modules, sorted by address like terraform show
+func stateModule(resources map[string][]Resource, address string) Module {
+	module := Module{
+		Address:   address,
+		Resources: resources[address],
+	}
+	var children []string
+	for child := range resources {
+		if child != "" && parentModule(child) == address {
+			children = append(children, child)
+		}
+	}
+	sort.Strings(children)
+	for _, child := range children {
+		module.ChildModules = append(module.ChildModules, stateModule(resources, child))
+	}
+	return module
+}
+
+// parentModule returns the address of the parent of a module, empty for the
+// root module
+func parentModule(address string) string {
+	if i := strings.LastIndex(address, ".module."); i >= 0 {
+		return address[:i]
+	}
+	return ""
+}
+
+// stateProviderName returns the provider of a provider configuration address
+// like provider["registry.terraform.io/hashicorp/aws"].west, or provider.aws
+// in terraform 0.12
+func stateProviderName(provider string) string {
+	if i := strings.Index(provider, "provider["); i >= 0 {
+		name := provider[i+len("provider["):]
+		if end := strings.Index(name, "]"); end >= 0 {
+			name = name[:end]
+		}
+		return strings.Trim(name, `"`)
+	}
+	if i := strings.Index(provider, "provider."); i >= 0 {
+		name := provider[i+len("provider."):]
+		if end := strings.Index(name, "."); end >= 0 {
+			name = name[:end]
+		}
+		return name
+	}
+	return provider
+}
+
+// markSensitive marks the value at path as sensitive in a structure like the
+// sensitive_values of terraform show, where lists are padded with false
+func markSensitive(node interface{}, path []sensitiveStep) interface{} {
+	if len(path) == 0 {
+		return true
+	}
+	var key interface{}
+	switch path[0].Type {
+	case "get_attr":
+		var name string
+		json.Unmarshal(path[0].Value, &name)
+		key = name
+	case "index":
+		var index struct {
+			Value interface{} `json:"value"`
+		}
+		json.Unmarshal(path[0].Value, &index)
+		key = index.Value
+	}
+	switch k := key.(type) {
+	case string:
+		m, ok := node.(map[string]interface{})
+		if !ok {
+			m = map[string]interface{}{}
+		}
+		m[k] = markSensitive(m[k], path[1:])
+		return m
+	case float64:
+		l, _ := node.([]interface{})
+		for len(l) <= int(k) {
+			l = append(l, false)
+		}
+		l[int(k)] = markSensitive(l[int(k)], path[1:])
+		return l
+	}
+	return node
+}
@@ -0,0 +1,208 @@
+package terralib
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+// The state read from the state file is the one shown by terraform, which
+// leaves out the lineage and serial
+func TestStateFileMatchesShowState(t *testing.T) {
+	f, err := LoadStateFile("testdata/state/terraform.tfstate")
+	if err != nil {
+		t.Fatalf("Got error: %v", err)
+	}
+	got, err := f.State()
+	if err != nil {
+		t.Fatalf("Got error: %v", err)
+	}
+	show, err := ioutil.ReadFile("testdata/golden/0.15.5/show_state.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var expected State
+	if err := json.Unmarshal(show, &expected); err != nil {
+		t.Fatal(err)
+	}
+	expected.FormatVersion = ""
+	expected.Lineage = "5b7a9e0c-3f1d-4c8e-a2b6-9d0f1e2c3a4b"
+	expected.Serial = 7
+	if diff := cmp.Diff(expected, got); diff != "" {
+		t.Errorf("State mismatch (-expected +got):\n%s", diff)
+	}
+}
+
+func TestReadStateFileModules(t *testing.T) {
+	r, err := os.Open("testdata/state/modules.tfstate")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+	f, err := ReadStateFile(r)
+	if err != nil {
+		t.Fatalf("Got error: %v", err)
+	}
+	state, err := f.State()
+	if err != nil {
+		t.Fatalf("Got error: %v", err)
+	}
+	if state.Lineage != "0f9c2d1e-7a6b-4e3c-8d2f-1a0b9c8d7e6f" || state.Serial != 42 {
+		t.Errorf("Got lineage %s and serial %d", state.Lineage, state.Serial)
+	}
+	expectedOutputs := map[string]OutputValue{
+		"db_password": {Sensitive: true, Value: "s3cr3t"},
+		"subnet_ids":  {Value: []interface{}{"subnet-0a1", "subnet-0b2"}},
+	}
+	if !cmp.Equal(state.Values.Outputs, expectedOutputs) {
+		t.Errorf("Got outputs: %+v, Expected: %+v", state.Values.Outputs, expectedOutputs)
+	}
+	expected := []Resource{
+		{
+			Address:         "data.aws_caller_identity.current",
+			Mode:            ModeData,
+			Type:            "aws_caller_identity",
+			Name:            "current",
+			ProviderName:    "registry.terraform.io/hashicorp/aws",
+			Values:          map[string]interface{}{"account_id": "123456789012", "id": "123456789012"},
+			SensitiveValues: map[string]interface{}{},
+		},
+		{
+			Address:         "aws_db_instance.main",
+			Mode:            ModeManaged,
+			Type:            "aws_db_instance",
+			Name:            "main",
+			ProviderName:    "registry.terraform.io/hashicorp/aws",
+			SchemaVersion:   1,
+			Values:          map[string]interface{}{"id": "db-1", "password": "s3cr3t", "tags": map[string]interface{}{"owner": "data"}},
+			SensitiveValues: map[string]interface{}{"password": true},
+			DependsOn:       []string{"module.net.aws_subnet.private"},
+		},
+		{
+			Address:         "aws_db_instance.main",
+			Mode:            ModeManaged,
+			Type:            "aws_db_instance",
+			Name:            "main",
+			ProviderName:    "registry.terraform.io/hashicorp/aws",
+			SchemaVersion:   1,
+			Values:          map[string]interface{}{"id": "db-0"},
+			SensitiveValues: map[string]interface{}{},
+			DeposedKey:      "00000001",
+		},
+		{
+			Address:         "module.net.aws_subnet.private[0]",
+			Mode:            ModeManaged,
+			Type:            "aws_subnet",
+			Name:            "private",
+			Index:           float64(0),
+			ProviderName:    "registry.terraform.io/hashicorp/aws",
+			SchemaVersion:   1,
+			Values:          map[string]interface{}{"id": "subnet-0a1", "cidr_block": "10.0.1.0/24"},
+			SensitiveValues: map[string]interface{}{},
+		},
+		{
+			Address:         "module.net.aws_subnet.private[1]",
+			Mode:            ModeManaged,
+			Type:            "aws_subnet",
+			Name:            "private",
+			Index:           float64(1),
+			ProviderName:    "registry.terraform.io/hashicorp/aws",
+			SchemaVersion:   1,
+			Values:          map[string]interface{}{"id": "subnet-0b2", "cidr_block": "10.0.2.0/24"},
+			SensitiveValues: map[string]interface{}{},
+			Tainted:         true,
+		},
+		{
+			Address:         `module.net.module.flow_logs["eu"].aws_flow_log.this["vpc"]`,
+			Mode:            ModeManaged,
+			Type:            "aws_flow_log",
+			Name:            "this",
+			Index:           "vpc",
+			ProviderName:    "aws",
+			Values:          map[string]interface{}{"id": "fl-1", "tags": map[string]interface{}{"team": "net", "secret": "x"}},
+			SensitiveValues: map[string]interface{}{"tags": map[string]interface{}{"secret": true}},
+		},
+	}
+	if diff := cmp.Diff(expected, state.Resources()); diff != "" {
+		t.Errorf("Resources mismatch (-expected +got):\n%s", diff)
+	}
+	net := state.Values.RootModule.ChildModules
+	if len(net) != 1 || net[0].Address != "module.net" ||
+		len(net[0].ChildModules) != 1 || net[0].ChildModules[0].Address != `module.net.module.flow_logs["eu"]` {
+		t.Errorf("Got modules: %+v", net)
+	}
+}
+
+func TestMarkSensitiveList(t *testing.T) {
+	var path []sensitiveStep
+	json.Unmarshal([]byte(`[{"type": "get_attr", "value": "ingress"}, {"type": "index", "value": {"value": 2, "type": "number"}}]`), &path)
+	got := markSensitive(map[string]interface{}{}, path)
+	expected := map[string]interface{}{"ingress": []interface{}{false, false, true}}
+	if !cmp.Equal(got, expected) {
+		t.Errorf("Got: %+v, Expected: %+v", got, expected)
+	}
+}
+
+func TestParseStateFileVersion(t *testing.T) {
+	_, err := ParseStateFile([]byte(`{"version": 3, "serial": 1, "modules": []}`))
+	if err == nil || err.Error() != "Unsupported state format version 3" {
+		t.Errorf("Got error: %v", err)
+	}
+}
+
+func TestStateFileBytes(t *testing.T) {
+	f, err := LoadStateFile("testdata/state/modules.tfstate")
+	if err != nil {
+		t.Fatalf("Got error: %v", err)
+	}
+	again, err := ParseStateFile(f.Bytes())
+	if err != nil {
+		t.Fatalf("Got error: %v", err)
+	}
+	expected, _ := f.State()
+	got, _ := again.State()
+	if diff := cmp.Diff(expected, got); diff != "" {
+		t.Errorf("State mismatch (-expected +got):\n%s", diff)
+	}
+}
+
+func TestShowStateStderr(t *testing.T) {
+	show, err := ioutil.ReadFile("testdata/golden/0.15.5/show_state.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	warning := "Warning: Deprecated parameter\n"
+	tests := []struct {
+		stdout []byte
+		code   string
+	}{
+		{show, ""},
+		{[]byte("No state."), ErrShowStateUnreadable},
+	}
+	for _, test := range tests {
+		tf := Terralib{
+			Executor: executorFunc(func(ctx context.Context, inv Invocation) (Result, error) {
+				return Result{
+					Output: append([]byte(warning), test.stdout...),
+					Stdout: test.stdout,
+					Stderr: []byte(warning),
+				}, nil
+			}),
+		}
+		state, err := tf.ShowState()
+		code := ""
+		if err != nil {
+			code = err.(ShowError).Code
+		}
+		if code != test.code {
+			t.Errorf("%q: Got: %v, Expected: %s", test.stdout, err, test.code)
+		}
+		if test.code == "" && state.TerraformVersion == "" {
+			t.Errorf("Got: %+v, Expected the state shown", state)
+		}
+	}
+}
@@ -0,0 +1,150 @@
+package terralib
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+)
+
+// Exported error codes
+const (
+	ErrStateLineageMismatch string = "errStateLineageMismatch"
+	ErrStateSerialLower     string = "errStateSerialLower"
+	ErrStateSerialConflict  string = "errStateSerialConflict"
+	ErrStateDefault         string = "errStateDefault"
+)
+
+var stateClassifiers = []Classifier{
+	NewClassifier(ErrStateLineageMismatch, `(?P<reason>cannot import state with lineage "[^"]*" over unrelated state with lineage "[^"]*")`,
+		CommandStatePush),
+	NewClassifier(ErrStateSerialLower, `(?P<reason>cannot import state with serial \d+ over newer state with serial \d+)`,
+		CommandStatePush),
+	NewClassifier(ErrStateSerialConflict, `(?P<reason>cannot overwrite existing state with serial \d+ with a different state that has the same serial)`,
+		CommandStatePush),
+	defaultClassifier(ErrStateDefault, CommandStatePull, CommandStatePush),
+}
+
+// StateError represents an error reading, editing or writing a state
+type StateError struct {
+	Reason string
+	Code   string
+}
+
+func (e StateError) Error() string {
+	return e.Code
+}
+
+// StatePushOutput represents the output of the state push command
+type StatePushOutput struct {
+	Raw      string
+	Attempts []Attempt
+}
+
+// CheckStatePush runs the checks of 'terraform state push' before writing
+// next over current: both must have the same lineage, and next a higher
+// serial, or the same one if nothing changed. current may be nil when there
+// is no state yet
+func CheckStatePush(current *StateFile, next *StateFile) error {
+	if current == nil {
+		return nil
+	}
+	if current.Lineage != "" && next.Lineage != current.Lineage {
+		return StateError{
+			Reason: fmt.Sprintf("cannot import state with lineage %q over unrelated state with lineage %q",
+				next.Lineage, current.Lineage),
+			Code: ErrStateLineageMismatch,
+		}
+	}
+	if next.Serial < current.Serial {
+		return StateError{
+			Reason: fmt.Sprintf("cannot import state with serial %d over newer state with serial %d",
+				next.Serial, current.Serial),
+			Code: ErrStateSerialLower,
+		}
+	}
+	if next.Serial == current.Serial && !bytes.Equal(next.Bytes(), current.Bytes()) {
+		return StateError{
+			Reason: fmt.Sprintf("cannot overwrite existing state with serial %d with a different state that has the same serial",
+				next.Serial),
+			Code: ErrStateSerialConflict,
+		}
+	}
+	return nil
+}
+
+// StatePull executes the 'terraform state pull' command and parses the state
+// of the backend. It returns nil when there is no state yet
+func (t *Terralib) StatePull() (stateFile *StateFile, err error) {
+	if err := t.beforeCommand(CommandStatePull, nil); err != nil {
+		return nil, err
+	}
+	defer func() {
+		t.afterCommand(CommandStatePull, nil, stateFile, err)
+	}()
+	result, match, _ := t.executeResult(CommandStatePull, nil, nil)
+	if match != nil {
+		return nil, StateError{
+			Reason: match.Reason,
+			Code:   match.Code,
+		}
+	}
+	stdout := resultStdout(result)
+	if len(bytes.TrimSpace(stdout)) == 0 {
+		return nil, nil
+	}
+	return ParseStateFile(stdout)
+}
+
+// StatePush writes a state to the backend with 'terraform state push', with
+// its serial incremented like Save. Unless force is set, the state of the
+// backend is pulled first to run the checks of CheckStatePush, and terraform
+// runs them again
+func (t *Terralib) StatePush(stateFile *StateFile, force bool) (statePushOutput StatePushOutput, err error) {
+	next := *stateFile
+	next.Serial++
+	if !force {
+		current, err := t.StatePull()
+		if err != nil {
+			return StatePushOutput{}, err
+		}
+		if err := CheckStatePush(current, &next); err != nil {
+			return StatePushOutput{}, err
+		}
+	}
+	f, err := ioutil.TempFile("", "terralib-*.tfstate")
+	if err != nil {
+		return StatePushOutput{}, err
+	}
+	defer os.Remove(f.Name())
+	_, err = f.Write(next.Bytes())
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return StatePushOutput{}, err
+	}
+	options := []string{f.Name()}
+	if force {
+		options = append([]string{"-force"}, options...)
+	}
+	if err := t.beforeCommand(CommandStatePush, options); err != nil {
+		return StatePushOutput{}, err
+	}
+	defer func() {
+		t.afterCommand(CommandStatePush, options, statePushOutput, err)
+	}()
+	stdOutputError, match, attempts := t.execute(CommandStatePush, options, nil)
+	statePushOutput = StatePushOutput{
+		Raw:      string(stdOutputError),
+		Attempts: attempts,
+	}
+	if match != nil {
+		return statePushOutput, StateError{
+			Reason: match.Reason,
+			Code:   match.Code,
+		}
+	}
+	stateFile.Serial = next.Serial
+	return statePushOutput, nil
+}
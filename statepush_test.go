@@ -0,0 +1,187 @@
+package terralib
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestCheckStatePush(t *testing.T) {
+	current := &StateFile{Lineage: "a", Serial: 5}
+	tests := []struct {
+		next *StateFile
+		code string
+	}{
+		{&StateFile{Lineage: "a", Serial: 6}, ""},
+		{&StateFile{Lineage: "a", Serial: 5}, ""},
+		{&StateFile{Lineage: "a", Serial: 5, TerraformVersion: "1.5.7"}, ErrStateSerialConflict},
+		{&StateFile{Lineage: "a", Serial: 4}, ErrStateSerialLower},
+		{&StateFile{Lineage: "b", Serial: 6}, ErrStateLineageMismatch},
+	}
+	for _, test := range tests {
+		err := CheckStatePush(current, test.next)
+		code := ""
+		if err != nil {
+			code = err.(StateError).Code
+		}
+		if code != test.code {
+			t.Errorf("%+v: Got: %v, Expected: %s", test.next, err, test.code)
+		}
+	}
+	if err := CheckStatePush(nil, &StateFile{Lineage: "b"}); err != nil {
+		t.Errorf("Got error without state: %v", err)
+	}
+}
+
+func TestStateFileSave(t *testing.T) {
+	dir, err := ioutil.TempDir("", "terralib")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, StateFileName)
+	original, err := ioutil.ReadFile("testdata/state/modules.tfstate")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(path, original, 0600); err != nil {
+		t.Fatal(err)
+	}
+	f, err := LoadStateFile(path)
+	if err != nil {
+		t.Fatalf("Got error: %v", err)
+	}
+	stale, _ := LoadStateFile(path)
+	if _, err := f.Remove("module.net"); err != nil {
+		t.Fatalf("Got error: %v", err)
+	}
+	backup, err := f.Save(path)
+	if err != nil {
+		t.Fatalf("Got error: %v", err)
+	}
+	if !strings.HasPrefix(backup, path+".") || !strings.HasSuffix(backup, ".backup") {
+		t.Errorf("Got backup: %s", backup)
+	}
+	saved, err := LoadStateFile(path)
+	if err != nil {
+		t.Fatalf("Got error: %v", err)
+	}
+	if saved.Serial != 43 || saved.Lineage != "0f9c2d1e-7a6b-4e3c-8d2f-1a0b9c8d7e6f" || len(saved.Resources) != 2 {
+		t.Errorf("Got serial %d, lineage %s and %d resources", saved.Serial, saved.Lineage, len(saved.Resources))
+	}
+	if f.Serial != 43 {
+		t.Errorf("Got serial %d after saving, Expected: 43", f.Serial)
+	}
+	previous, err := LoadStateFile(backup)
+	if err != nil {
+		t.Fatalf("Got error: %v", err)
+	}
+	if previous.Serial != 42 || len(previous.Resources) != 4 {
+		t.Errorf("Got backup with serial %d and %d resources", previous.Serial, len(previous.Resources))
+	}
+
+	// A second save in the same second keeps both backups
+	again, err := f.Save(path)
+	if err != nil {
+		t.Fatalf("Got error: %v", err)
+	}
+	if again == backup {
+		t.Errorf("Got the same backup %s twice", backup)
+	}
+	if previous, _ := LoadStateFile(backup); previous == nil || previous.Serial != 42 {
+		t.Errorf("The first backup was replaced")
+	}
+	if previous, _ := LoadStateFile(again); previous == nil || previous.Serial != 43 {
+		t.Errorf("Got second backup: %+v", previous)
+	}
+
+	// A state read before the last save can not replace it
+	stale.Remove("aws_db_instance.main")
+	stale.Serial = 43
+	if _, err := stale.Save(path); err == nil || err.(StateError).Code != ErrStateSerialConflict {
+		t.Errorf("Got: %v, Expected: %s", err, ErrStateSerialConflict)
+	}
+	unrelated, _ := NewStateFile()
+	if _, err := unrelated.Save(path); err == nil || err.(StateError).Code != ErrStateLineageMismatch {
+		t.Errorf("Got: %v, Expected: %s", err, ErrStateLineageMismatch)
+	}
+	if current, _ := LoadStateFile(path); current.Serial != 44 || len(current.Resources) != 2 {
+		t.Errorf("The state was replaced")
+	}
+}
+
+func TestStatePush(t *testing.T) {
+	current, err := ioutil.ReadFile("testdata/state/modules.tfstate")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var pushed [][]string
+	var pushedSerial uint64
+	tf := Terralib{
+		Executor: executorFunc(func(ctx context.Context, inv Invocation) (Result, error) {
+			if inv.Args[1] == "pull" {
+				return Result{Output: current}, nil
+			}
+			pushed = append(pushed, inv.Args)
+			if f, err := LoadStateFile(inv.Args[len(inv.Args)-1]); err == nil {
+				pushedSerial = f.Serial
+			}
+			return Result{}, nil
+		}),
+	}
+	f, _ := ParseStateFile(current)
+	f.Remove("module.net")
+	if _, err := tf.StatePush(f, false); err != nil {
+		t.Fatalf("Got error: %v", err)
+	}
+	if len(pushed) != 1 || len(pushed[0]) != 3 || pushed[0][0] != "state" || pushed[0][1] != "push" {
+		t.Errorf("Got pushes: %v", pushed)
+	}
+	if pushedSerial != 43 || f.Serial != 43 {
+		t.Errorf("Got pushed serial %d and serial %d, Expected: 43", pushedSerial, f.Serial)
+	}
+
+	f.Serial = 40
+	_, err = tf.StatePush(f, false)
+	if err == nil || err.(StateError).Code != ErrStateSerialLower {
+		t.Errorf("Got: %v, Expected: %s", err, ErrStateSerialLower)
+	}
+	if len(pushed) != 1 {
+		t.Errorf("Pushed a state with a lower serial")
+	}
+	if _, err := tf.StatePush(f, true); err != nil || len(pushed) != 2 || pushed[1][2] != "-force" {
+		t.Errorf("Got: %v, %v", err, pushed)
+	}
+}
+
+func TestStatePushError(t *testing.T) {
+	output := `Failed to write state: cannot import state with lineage "b" over unrelated state with lineage "a"`
+	match := DefaultClassifiers.Classify(CommandStatePush, "", []byte(output))
+	if match == nil || match.Code != ErrStateLineageMismatch {
+		t.Errorf("Got: %+v, Expected: %s", match, ErrStateLineageMismatch)
+	}
+}
+
+func TestStatePullStderr(t *testing.T) {
+	current, err := ioutil.ReadFile("testdata/state/modules.tfstate")
+	if err != nil {
+		t.Fatal(err)
+	}
+	warning := "Warning: Deprecated parameter\n\nThe parameter \"dynamodb_table\" is deprecated.\n"
+	tf := Terralib{
+		Executor: executorFunc(func(ctx context.Context, inv Invocation) (Result, error) {
+			return Result{
+				Output: append([]byte(warning), current...),
+				Stdout: current,
+				Stderr: []byte(warning),
+			}, nil
+		}),
+	}
+	f, err := tf.StatePull()
+	if err != nil || f == nil || f.Serial != 42 {
+		t.Errorf("Got: %+v, %v, Expected the state with serial 42", f, err)
+	}
+}
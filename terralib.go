@@ -1,6 +1,66 @@
 package terralib
 
+import (
+	"context"
+	"time"
+)
+
 // Terralib struct holds the configuration for terralib
 type Terralib struct {
 	ConfigPath string
+	// TerraformVersion is the version of the terraform binary in use, see
+	// DetectVersion. It scopes the error classifiers and is logged, leave it
+	// empty to try classifiers of every version
+	TerraformVersion string
+	// Classifiers is the registry used to classify errors. DefaultClassifiers
+	// is used when nil
+	Classifiers *ClassifierRegistry
+	// LockWait is how long Plan and Apply keep trying when the state is locked
+	// by another process, checking every LockPollInterval (10s by default)
+	LockWait         time.Duration
+	LockPollInterval time.Duration
+	// Retry holds the retry policy of each command, by command name. Apply
+	// is not retried when it is given a plan file, as ApplyRun does
+	Retry map[string]*RetryPolicy
+	// Policies are evaluated against every plan, see Plan and Apply
+	Policies *PolicySet
+	// Logger receives a record of every command run, at LogLevel or above
+	Logger   Logger
+	LogLevel LogLevel
+	// Hooks are called around every command, in order
+	Hooks []Hooks
+	// Variables are passed to Plan, Apply and Destroy, encoded as JSON in a
+	// temporary file or, when VariablesAs is VariablesEnv, as TF_VAR_
+	// environment variables, so they are not shown in the process list. See
+	// WithVariables to pass variables to a single command
+	Variables   map[string]interface{}
+	VariablesAs string
+	// AllowInput lets terraform ask for input. Otherwise -input=false is added
+	// to Init, Plan, Apply and Destroy, which fail instead of waiting
+	AllowInput bool
+	// Prompter answers the questions asked by terraform. When nil, a command
+	// asking a question is stopped and fails with ErrInteractivePrompt
+	Prompter Prompter
+	// Backend is the backend configuration passed to Init
+	Backend *BackendConfig
+	// Executor runs the terraform commands, ShellExecutor when nil
+	Executor Executor
+	// Context cancels the running command and the waits between its
+	// attempts. context.Background is used when nil
+	Context context.Context
+}
+
+func (t *Terralib) context() context.Context {
+	if t.Context == nil {
+		return context.Background()
+	}
+	return t.Context
+}
+
+func (t *Terralib) classify(command string, output []byte) *Match {
+	registry := t.Classifiers
+	if registry == nil {
+		registry = DefaultClassifiers
+	}
+	return registry.Classify(command, t.TerraformVersion, output)
 }
@@ -0,0 +1,306 @@
+package terralibtest
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/amongil/terralib"
+)
+
+// tempFileRegexp matches the temporary files terralib passes to terraform,
+// like -var-file=/tmp/terralib-123.tfvars.json, whose names change on every run
+var tempFileRegexp = regexp.MustCompile(`^(-[a-z-]+=)?(?:.*[/\\])?terralib-[0-9]+((?:\.[a-z]+)+)$`)
+
+// Interaction represents a recorded terraform command and its result
+type Interaction struct {
+	// Args are recorded with the temporary files of terralib renamed
+	// terralib-*, like terralib-*.tfplan
+	Args []string `json:"args"`
+	// Env holds the allowlisted environment variables the command ran with
+	Env      []string      `json:"env,omitempty"`
+	Output   string        `json:"output"`
+	Stdout   string        `json:"stdout"`
+	Stderr   string        `json:"stderr"`
+	ExitCode int           `json:"exit_code"`
+	Duration time.Duration `json:"duration"`
+	// Plan is the JSON of the plan file written by a plan command with -out
+	Plan json.RawMessage `json:"plan,omitempty"`
+}
+
+// Cassette represents the interactions of a terraform session
+type Cassette struct {
+	Interactions []Interaction `json:"interactions"`
+}
+
+// LoadCassette reads a cassette file
+func LoadCassette(path string) (*Cassette, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var cassette Cassette
+	if err := json.Unmarshal(data, &cassette); err != nil {
+		return nil, fmt.Errorf("Could not parse cassette %s: %v", path, err)
+	}
+	return &cassette, nil
+}
+
+// Save writes the cassette to a file
+func (c *Cassette) Save(path string) error {
+	data, err := json.MarshalIndent(c, "", "  ")
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(path, append(data, '\n'), 0644)
+}
+
+// Recorder is a terralib.Executor that runs commands with another executor
+// and records them in a cassette
+type Recorder struct {
+	// Executor runs the commands, terralib.ShellExecutor when nil
+	Executor terralib.Executor
+	// EnvAllowlist are the names of the environment variables recorded.
+	// Others are left out of the cassette as they may hold credentials
+	EnvAllowlist []string
+
+	mu       sync.Mutex
+	cassette Cassette
+}
+
+// NewRecorder creates a recorder running commands with the given executor
+func NewRecorder(executor terralib.Executor, envAllowlist ...string) *Recorder {
+	return &Recorder{
+		Executor:     executor,
+		EnvAllowlist: envAllowlist,
+	}
+}
+
+// Execute runs and records the invocation. The plan file written by a
+// successful plan command is recorded with 'terraform show -json'
+func (r *Recorder) Execute(ctx context.Context, inv terralib.Invocation) (terralib.Result, error) {
+	executor := r.executor()
+	result, err := executor.Execute(ctx, inv)
+	if err != nil {
+		return result, err
+	}
+	interaction := Interaction{
+		Args:     normalizeArgs(inv.Args),
+		Env:      r.allowedEnv(inv.Env),
+		Output:   string(result.Output),
+		Stdout:   string(result.Stdout),
+		Stderr:   string(result.Stderr),
+		ExitCode: result.ExitCode,
+		Duration: result.Duration,
+	}
+	if planFile := outOption(inv.Args); planFile != "" && result.ExitCode == 0 {
+		show, err := executor.Execute(ctx, terralib.Invocation{
+			Args: []string{terralib.CommandShow, "-no-color", "-json", planFile},
+			Dir:  inv.Dir,
+			Env:  inv.Env,
+		})
+		if err == nil && show.ExitCode == 0 && json.Valid(show.Stdout) {
+			interaction.Plan = json.RawMessage(show.Stdout)
+		}
+	}
+	r.mu.Lock()
+	r.cassette.Interactions = append(r.cassette.Interactions, interaction)
+	r.mu.Unlock()
+	return result, nil
+}
+
+// Cassette returns the recorded interactions
+func (r *Recorder) Cassette() *Cassette {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return &Cassette{
+		Interactions: append([]Interaction(nil), r.cassette.Interactions...),
+	}
+}
+
+// Save writes the recorded interactions to a cassette file
+func (r *Recorder) Save(path string) error {
+	return r.Cassette().Save(path)
+}
+
+func (r *Recorder) executor() terralib.Executor {
+	if r.Executor == nil {
+		return terralib.ShellExecutor{}
+	}
+	return r.Executor
+}
+
+// allowedEnv returns the allowlisted variables of the process environment,
+// overridden by the ones of the invocation
+func (r *Recorder) allowedEnv(env []string) []string {
+	values := map[string]string{}
+	for _, variable := range append(os.Environ(), env...) {
+		if i := strings.Index(variable, "="); i > 0 {
+			values[variable[:i]] = variable[i+1:]
+		}
+	}
+	var allowed []string
+	for _, name := range r.EnvAllowlist {
+		if value, ok := values[name]; ok {
+			allowed = append(allowed, name+"="+value)
+		}
+	}
+	return allowed
+}
+
+// Replayer is a terralib.Executor that serves the interactions of a cassette
+// in order, failing on commands that were not recorded
+type Replayer struct {
+	// Realtime waits the recorded duration of every interaction
+	Realtime bool
+
+	mu       sync.Mutex
+	cassette *Cassette
+	next     int
+}
+
+// NewReplayer creates a replayer of the given cassette
+func NewReplayer(cassette *Cassette) *Replayer {
+	return &Replayer{cassette: cassette}
+}
+
+// Execute returns the result of the next interaction when its arguments
+// match the invocation. 'terraform show -json' of a plan file is served from
+// the plan recorded with it when the command itself was not recorded
+func (r *Replayer) Execute(ctx context.Context, inv terralib.Invocation) (terralib.Result, error) {
+	interaction, err := r.interaction(inv)
+	if err != nil {
+		return terralib.Result{ExitCode: -1}, err
+	}
+	if r.Realtime && interaction.Duration > 0 {
+		timer := time.NewTimer(interaction.Duration)
+		defer timer.Stop()
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			return terralib.Result{ExitCode: -1}, ctx.Err()
+		}
+	}
+	if inv.Stdout != nil {
+		io.WriteString(inv.Stdout, interaction.Output)
+	}
+	return terralib.Result{
+		Output:   []byte(interaction.Output),
+		Stdout:   []byte(interaction.Stdout),
+		Stderr:   []byte(interaction.Stderr),
+		ExitCode: interaction.ExitCode,
+		Duration: interaction.Duration,
+	}, nil
+}
+
+// Done returns an error if some interactions were not replayed
+func (r *Replayer) Done() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if left := len(r.cassette.Interactions) - r.next; left > 0 {
+		return fmt.Errorf("%d interactions were not replayed, next: terraform %s",
+			left, strings.Join(r.cassette.Interactions[r.next].Args, " "))
+	}
+	return nil
+}
+
+func (r *Replayer) interaction(inv terralib.Invocation) (Interaction, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.next < len(r.cassette.Interactions) {
+		interaction := r.cassette.Interactions[r.next]
+		if equalArgs(normalizeArgs(interaction.Args), normalizeArgs(inv.Args)) {
+			r.next++
+			return interaction, nil
+		}
+	}
+	if plan := r.recordedPlan(inv.Args); plan != nil {
+		return Interaction{
+			Args:   inv.Args,
+			Output: string(plan),
+			Stdout: string(plan),
+		}, nil
+	}
+	if r.next < len(r.cassette.Interactions) {
+		return Interaction{}, fmt.Errorf("Unexpected command terraform %s, expected: terraform %s",
+			strings.Join(inv.Args, " "), strings.Join(r.cassette.Interactions[r.next].Args, " "))
+	}
+	return Interaction{}, fmt.Errorf("Unexpected command terraform %s, the cassette has no interactions left",
+		strings.Join(inv.Args, " "))
+}
+
+// recordedPlan returns the plan recorded for the plan file of a show command
+func (r *Replayer) recordedPlan(args []string) json.RawMessage {
+	if len(args) < 2 || args[0] != terralib.CommandShow || !containsArg(args, "-json") {
+		return nil
+	}
+	planFile := normalizeArg(args[len(args)-1])
+	for i := r.next - 1; i >= 0; i-- {
+		interaction := r.cassette.Interactions[i]
+		if interaction.Plan != nil && normalizeArg(outOption(interaction.Args)) == planFile {
+			return interaction.Plan
+		}
+	}
+	return nil
+}
+
+// outOption returns the plan file of a plan command, given as -out=file or
+// -out file. Terraform keeps the last -out option
+func outOption(args []string) string {
+	if len(args) == 0 || args[0] != terralib.CommandPlan {
+		return ""
+	}
+	var planFile string
+	for i := 1; i < len(args); i++ {
+		switch {
+		case args[i] == "-out" && i+1 < len(args):
+			i++
+			planFile = args[i]
+		case strings.HasPrefix(args[i], "-out="):
+			planFile = strings.TrimPrefix(args[i], "-out=")
+		}
+	}
+	return planFile
+}
+
+// normalizeArgs returns a copy of the arguments with the temporary files of
+// terralib renamed terralib-*, so they match across runs
+func normalizeArgs(args []string) []string {
+	normalized := make([]string, len(args))
+	for i, arg := range args {
+		normalized[i] = normalizeArg(arg)
+	}
+	return normalized
+}
+
+func normalizeArg(arg string) string {
+	return tempFileRegexp.ReplaceAllString(arg, "${1}terralib-*${2}")
+}
+
+func equalArgs(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func containsArg(args []string, arg string) bool {
+	for _, a := range args {
+		if a == arg {
+			return true
+		}
+	}
+	return false
+}
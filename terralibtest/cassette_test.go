@@ -0,0 +1,156 @@
+package terralibtest
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/amongil/terralib"
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestRecordAndReplay(t *testing.T) {
+	os.Setenv("TERRALIBTEST_REGION", "eu-west-1")
+	defer os.Unsetenv("TERRALIBTEST_REGION")
+	os.Setenv("TERRALIBTEST_SECRET", "hunter2")
+	defer os.Unsetenv("TERRALIBTEST_SECRET")
+
+	fake := NewFakeExecutor().
+		On(terralib.CommandInit, InitSuccess).
+		On(terralib.CommandPlan, PlanChanges).
+		On(terralib.CommandShow, ShowPlan).
+		On(terralib.CommandApply, ApplySuccess)
+	recorder := NewRecorder(fake, "TERRALIBTEST_REGION")
+	tf := terralib.Terralib{Executor: recorder}
+	if _, err := tf.Init(nil); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := tf.Plan([]string{"-out=plan.tfplan"}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := tf.Apply([]string{"plan.tfplan"}); err != nil {
+		t.Fatal(err)
+	}
+
+	dir, err := ioutil.TempDir("", "terralibtest")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "session.json")
+	if err := recorder.Save(path); err != nil {
+		t.Fatal(err)
+	}
+	data, _ := ioutil.ReadFile(path)
+	if strings.Contains(string(data), "hunter2") {
+		t.Errorf("Expected variables out of the allowlist not to be recorded")
+	}
+	cassette, err := LoadCassette(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ignorePlan := cmp.FilterPath(func(p cmp.Path) bool {
+		return p.Last().String() == ".Plan"
+	}, cmp.Ignore())
+	if !cmp.Equal(cassette, recorder.Cassette(), ignorePlan) {
+		t.Errorf("Got: %+v, Expected: %+v", cassette, recorder.Cassette())
+	}
+	if env := cassette.Interactions[0].Env; !cmp.Equal(env, []string{"TERRALIBTEST_REGION=eu-west-1"}) {
+		t.Errorf("Got: %v, Expected only the allowlisted variables", env)
+	}
+	var plan terralib.ShowOutput
+	if err := json.Unmarshal(cassette.Interactions[1].Plan, &plan); err != nil || len(plan.Changes) != 1 {
+		t.Errorf("Got: %+v, Expected the plan JSON to be recorded", plan)
+	}
+
+	replayer := NewReplayer(cassette)
+	tf = terralib.Terralib{
+		Executor: replayer,
+		Policies: terralib.NewPolicySet(terralib.DenyDelete()),
+	}
+	if _, err := tf.Init(nil); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := tf.Plan([]string{"-out=plan.tfplan"}); err != nil {
+		t.Fatal(err)
+	}
+	// The policies are evaluated on the recorded plan
+	output, err := tf.Apply([]string{"plan.tfplan"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if output.Raw != ApplySuccess.Output {
+		t.Errorf("Got: %q, Expected: %q", output.Raw, ApplySuccess.Output)
+	}
+	if err := replayer.Done(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestReplayUnexpectedCommand(t *testing.T) {
+	replayer := NewReplayer(&Cassette{
+		Interactions: []Interaction{{Args: []string{"init"}}},
+	})
+	tf := terralib.Terralib{Executor: replayer}
+	_, err := tf.Plan(nil)
+	if err == nil || err.(terralib.PlanError).Code != terralib.ErrCommandNotRun {
+		t.Errorf("Got: %v, Expected: %s", err, terralib.ErrCommandNotRun)
+	}
+	if err := replayer.Done(); err == nil {
+		t.Errorf("Expected an error as the init interaction was not replayed")
+	}
+}
+
+func TestReplayTemporaryFiles(t *testing.T) {
+	fake := NewFakeExecutor().
+		On(terralib.CommandPlan, PlanChanges).
+		On(terralib.CommandShow, ShowPlan)
+	recorder := NewRecorder(fake)
+	tf := terralib.Terralib{
+		Executor:  recorder,
+		Policies:  terralib.NewPolicySet(terralib.DenyDelete()),
+		Variables: map[string]interface{}{"region": "eu-west-1"},
+	}
+	if _, err := tf.Plan(nil); err != nil {
+		t.Fatal(err)
+	}
+	cassette := recorder.Cassette()
+	expected := []string{"plan", "-var-file=terralib-*.tfvars.json", "-input=false", "-out=terralib-*.tfplan"}
+	if len(cassette.Interactions) != 2 || !cmp.Equal(cassette.Interactions[0].Args, expected) {
+		t.Fatalf("Got: %+v, Expected: %v", cassette.Interactions, expected)
+	}
+
+	// The temporary files have other names when replaying
+	replayer := NewReplayer(cassette)
+	tf.Executor = replayer
+	output, err := tf.Plan(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if output.Plan == nil || len(output.Plan.Changes) != 1 {
+		t.Errorf("Got: %+v, Expected the recorded plan", output.Plan)
+	}
+	if err := replayer.Done(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestOutOption(t *testing.T) {
+	tests := []struct {
+		args     []string
+		expected string
+	}{
+		{[]string{terralib.CommandPlan, "-out=plan.tfplan"}, "plan.tfplan"},
+		{[]string{terralib.CommandPlan, "-out", "plan.tfplan", "-lock=false"}, "plan.tfplan"},
+		{[]string{terralib.CommandPlan, "-out", "first.tfplan", "-out=last.tfplan"}, "last.tfplan"},
+		{[]string{terralib.CommandApply, "-out", "plan.tfplan"}, ""},
+	}
+	for _, test := range tests {
+		if got := outOption(test.args); got != test.expected {
+			t.Errorf("Got: %q, Expected: %q for %v", got, test.expected, test.args)
+		}
+	}
+}
@@ -0,0 +1,189 @@
+// Package terralibtest provides an executor to test code using terralib
+// without running terraform
+package terralibtest
+
+import (
+	"bufio"
+	"context"
+	"io"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/amongil/terralib"
+)
+
+// Response represents the scripted result of a command
+type Response struct {
+	// Output is written to the output of the invocation. When it ends with
+	// a question, like terraform's "Enter a value: ", a line is read from
+	// the input before writing AfterPrompt
+	Output      string
+	AfterPrompt string
+	ExitCode    int
+	// Delay is waited before responding, the invocation fails if its
+	// context is cancelled meanwhile
+	Delay time.Duration
+	// Err is returned when the command could not be run
+	Err error
+}
+
+// FakeExecutor is a terralib.Executor that records the invocations and
+// returns scripted responses
+type FakeExecutor struct {
+	// Default is returned for commands without scripted responses
+	Default Response
+
+	mu          sync.Mutex
+	responses   map[string][]Response
+	invocations []terralib.Invocation
+	answers     []string
+}
+
+// NewFakeExecutor creates an executor without scripted responses
+func NewFakeExecutor() *FakeExecutor {
+	return &FakeExecutor{
+		responses: map[string][]Response{},
+	}
+}
+
+// On scripts the responses of a command, which may have several words like
+// "state pull". They are returned in order and the last one is repeated once
+// the others are used
+func (f *FakeExecutor) On(command string, responses ...Response) *FakeExecutor {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.responses[command] = append(f.responses[command], responses...)
+	return f
+}
+
+// Execute records the invocation and returns the next response of its command
+func (f *FakeExecutor) Execute(ctx context.Context, inv terralib.Invocation) (terralib.Result, error) {
+	response := f.record(inv)
+	if response.Delay > 0 {
+		timer := time.NewTimer(response.Delay)
+		defer timer.Stop()
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			return terralib.Result{ExitCode: -1}, ctx.Err()
+		}
+	}
+	if response.Err != nil {
+		return terralib.Result{ExitCode: -1}, response.Err
+	}
+	output := response.Output
+	if inv.Stdout != nil {
+		io.WriteString(inv.Stdout, output)
+	}
+	if strings.HasSuffix(strings.TrimRight(output, " "), "Enter a value:") {
+		answer, err := f.readAnswer(ctx, inv.Stdin)
+		if err != nil {
+			return terralib.Result{Output: []byte(output), ExitCode: -1}, err
+		}
+		output += answer + "\n" + response.AfterPrompt
+		if inv.Stdout != nil {
+			io.WriteString(inv.Stdout, answer+"\n"+response.AfterPrompt)
+		}
+	}
+	return terralib.Result{
+		Output:   []byte(output),
+		Stdout:   []byte(output),
+		ExitCode: response.ExitCode,
+		Duration: response.Delay,
+	}, nil
+}
+
+// readAnswer reads a line of the input, like terraform waiting for an answer
+func (f *FakeExecutor) readAnswer(ctx context.Context, stdin io.Reader) (string, error) {
+	if stdin == nil {
+		return "", io.EOF
+	}
+	lines := make(chan string, 1)
+	errs := make(chan error, 1)
+	go func() {
+		line, err := bufio.NewReader(stdin).ReadString('\n')
+		if err != nil {
+			errs <- err
+			return
+		}
+		lines <- strings.TrimSuffix(line, "\n")
+	}()
+	select {
+	case line := <-lines:
+		f.mu.Lock()
+		f.answers = append(f.answers, line)
+		f.mu.Unlock()
+		return line, nil
+	case err := <-errs:
+		return "", err
+	case <-ctx.Done():
+		return "", ctx.Err()
+	}
+}
+
+// Answers returns the lines read from the input of the invocations, in order
+func (f *FakeExecutor) Answers() []string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]string(nil), f.answers...)
+}
+
+func (f *FakeExecutor) record(inv terralib.Invocation) Response {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	inv.Args = append([]string(nil), inv.Args...)
+	inv.Env = append([]string(nil), inv.Env...)
+	// The input and output are only valid while running
+	inv.Stdin = nil
+	inv.Stdout = nil
+	f.invocations = append(f.invocations, inv)
+	var command string
+	for scripted := range f.responses {
+		if isCommand(inv.Args, scripted) && len(scripted) > len(command) {
+			command = scripted
+		}
+	}
+	responses := f.responses[command]
+	switch len(responses) {
+	case 0:
+		return f.Default
+	case 1:
+		return responses[0]
+	}
+	f.responses[command] = responses[1:]
+	return responses[0]
+}
+
+// Invocations returns the recorded invocations, in order
+func (f *FakeExecutor) Invocations() []terralib.Invocation {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]terralib.Invocation(nil), f.invocations...)
+}
+
+// Calls returns the recorded invocations of a command, in order. The calls
+// of "state" include those of "state pull"
+func (f *FakeExecutor) Calls(command string) []terralib.Invocation {
+	var calls []terralib.Invocation
+	for _, inv := range f.Invocations() {
+		if isCommand(inv.Args, command) {
+			calls = append(calls, inv)
+		}
+	}
+	return calls
+}
+
+// isCommand tells whether the arguments start with the words of command
+func isCommand(args []string, command string) bool {
+	words := strings.Fields(command)
+	if len(words) == 0 || len(words) > len(args) {
+		return false
+	}
+	for i, word := range words {
+		if args[i] != word {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,186 @@
+package terralibtest
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/amongil/terralib"
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestFakeExecutorRecordsInvocations(t *testing.T) {
+	fake := NewFakeExecutor().On(terralib.CommandInit, InitSuccess)
+	tf := terralib.Terralib{
+		ConfigPath: "/tmp/config",
+		Executor:   fake,
+	}
+	output, err := tf.Init([]string{"-no-color"})
+	if err != nil {
+		t.Fatalf("Got: %v, Expected no error", err)
+	}
+	expected := []terralib.Provider{{Name: "aws", Path: "hashicorp/aws", Version: "2.60.0"}}
+	if !cmp.Equal(output.InitializedProviders, expected) {
+		t.Errorf("Got: %+v, Expected: %+v", output.InitializedProviders, expected)
+	}
+	invocations := []terralib.Invocation{{
+		Args: []string{"init", "-input=false", "-no-color"},
+		Dir:  "/tmp/config",
+	}}
+	if !cmp.Equal(fake.Invocations(), invocations, cmp.FilterPath(func(p cmp.Path) bool {
+		return p.Last().String() == ".Env"
+	}, cmp.Ignore())) {
+		t.Errorf("Got: %+v, Expected: %+v", fake.Invocations(), invocations)
+	}
+}
+
+func TestFakeExecutorScriptedResponses(t *testing.T) {
+	fake := NewFakeExecutor().On(terralib.CommandApply, ApplyThrottled, ApplySuccess)
+	tf := terralib.Terralib{
+		Executor: fake,
+		Retry: map[string]*terralib.RetryPolicy{
+			terralib.CommandApply: {
+				MaxAttempts: 3,
+				Backoff:     time.Millisecond,
+				Codes:       []string{terralib.ErrThrottled},
+			},
+		},
+	}
+	output, err := tf.Apply([]string{"-auto-approve"})
+	if err != nil {
+		t.Fatalf("Got: %v, Expected no error", err)
+	}
+	codes := []string{}
+	for _, attempt := range output.Attempts {
+		codes = append(codes, attempt.Code)
+	}
+	if !cmp.Equal(codes, []string{terralib.ErrThrottled, ""}) {
+		t.Errorf("Got: %v, Expected a throttled attempt followed by a successful one", codes)
+	}
+	if calls := len(fake.Calls(terralib.CommandApply)); calls != 2 {
+		t.Errorf("Got: %d calls, Expected: 2", calls)
+	}
+}
+
+func TestFakeExecutorFixtures(t *testing.T) {
+	tests := []struct {
+		name     string
+		response Response
+		run      func(tf *terralib.Terralib) error
+		code     string
+	}{
+		{
+			name:     "provider not found",
+			response: InitProviderNotFound,
+			run:      func(tf *terralib.Terralib) error { _, err := tf.Init(nil); return err },
+			code:     terralib.ErrProviderNotFound,
+		},
+		{
+			name:     "registry unreachable",
+			response: InitRegistryUnreachable,
+			run:      func(tf *terralib.Terralib) error { _, err := tf.Init(nil); return err },
+			code:     terralib.ErrDiscoveryServiceUnreachable,
+		},
+		{
+			name:     "invalid resource type",
+			response: PlanInvalidResourceType,
+			run:      func(tf *terralib.Terralib) error { _, err := tf.Plan(nil); return err },
+			code:     terralib.ErrInvalidResourceType,
+		},
+		{
+			name:     "state locked",
+			response: StateLocked,
+			run:      func(tf *terralib.Terralib) error { _, err := tf.Plan(nil); return err },
+			code:     terralib.ErrStateLocked,
+		},
+		{
+			name:     "already exists",
+			response: ApplyAlreadyExists,
+			run:      func(tf *terralib.Terralib) error { _, err := tf.Apply(nil); return err },
+			code:     terralib.ErrResourceAlreadyExists,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			fake := NewFakeExecutor()
+			fake.Default = test.response
+			err := test.run(&terralib.Terralib{Executor: fake})
+			if err == nil || err.Error() != test.code {
+				t.Errorf("Got: %v, Expected: %s", err, test.code)
+			}
+		})
+	}
+}
+
+func TestFakeExecutorShowPlan(t *testing.T) {
+	tf := terralib.Terralib{
+		Executor: NewFakeExecutor().On(terralib.CommandShow, ShowPlan),
+	}
+	output, err := tf.Show("plan.tfplan")
+	if err != nil {
+		t.Fatalf("Got: %v, Expected no error", err)
+	}
+	if len(output.Changes) != 1 || output.Changes[0].Change.Action() != terralib.ActionCreate {
+		t.Errorf("Got: %+v, Expected one resource to be created", output.Changes)
+	}
+}
+
+func TestFakeExecutorDelay(t *testing.T) {
+	fake := NewFakeExecutor().On(terralib.CommandPlan, Response{Delay: time.Minute})
+	ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond)
+	defer cancel()
+	_, err := fake.Execute(ctx, terralib.Invocation{Args: []string{terralib.CommandPlan}})
+	if err != context.DeadlineExceeded {
+		t.Errorf("Got: %v, Expected: %v", err, context.DeadlineExceeded)
+	}
+}
+
+func TestFakeExecutorPrompt(t *testing.T) {
+	fake := NewFakeExecutor().On(terralib.CommandApply, ApplyApprovalPrompt)
+	tf := terralib.Terralib{
+		Executor: fake,
+		Prompter: terralib.PrompterFunc(func(prompt terralib.Prompt) (string, error) {
+			return "yes", nil
+		}),
+	}
+	output, err := tf.Apply(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !cmp.Equal(fake.Answers(), []string{"yes"}) {
+		t.Errorf("Got: %v, Expected the prompt to be answered", fake.Answers())
+	}
+	if !strings.HasSuffix(output.Raw, ApplyApprovalPrompt.AfterPrompt) {
+		t.Errorf("Got: %q, Expected the output after the answer", output.Raw)
+	}
+
+	tf.Prompter = nil
+	_, err = tf.Apply(nil)
+	if applyError, ok := err.(terralib.ApplyError); !ok || applyError.Code != terralib.ErrInteractivePrompt {
+		t.Errorf("Got: %v, Expected: %s", err, terralib.ErrInteractivePrompt)
+	}
+}
+
+func TestFakeExecutorMultiWordCommands(t *testing.T) {
+	state, err := terralib.NewStateFile()
+	if err != nil {
+		t.Fatal(err)
+	}
+	fake := NewFakeExecutor().
+		On("state", Response{Output: "Usage: terraform state <subcommand>", ExitCode: 1}).
+		On(terralib.CommandStatePull, Response{Output: string(state.Bytes())}).
+		On(terralib.CommandStatePush, Response{})
+	tf := terralib.Terralib{Executor: fake}
+	if _, err := tf.StatePush(state, false); err != nil {
+		t.Fatalf("Got: %v, Expected no error", err)
+	}
+	pull := fake.Calls(terralib.CommandStatePull)
+	push := fake.Calls(terralib.CommandStatePush)
+	if len(pull) != 1 || len(push) != 1 || len(fake.Calls("state")) != 2 {
+		t.Errorf("Got: %+v, Expected a pull and a push", fake.Invocations())
+	}
+	if len(push) == 1 && !strings.HasSuffix(push[0].Args[len(push[0].Args)-1], ".tfstate") {
+		t.Errorf("Got: %v, Expected the state file to be pushed", push[0].Args)
+	}
+}
@@ -0,0 +1,174 @@
+package terralibtest
+
+import (
+	"fmt"
+)
+
+// Version returns the response of 'terraform version'
+func Version(version string) Response {
+	return Response{
+		Output: fmt.Sprintf("Terraform v%s\non linux_amd64\n", version),
+	}
+}
+
+// Canned responses for common successes and failures
+var (
+	InitSuccess = Response{
+		Output: `
+Initializing the backend...
+
+Initializing provider plugins...
+- Checking for available provider plugins...
+- Downloading plugin for provider "aws" (hashicorp/aws) 2.60.0...
+
+Terraform has been successfully initialized!
+`,
+	}
+
+	InitProviderNotFound = Response{
+		Output: `
+Initializing the backend...
+
+Initializing provider plugins...
+- Checking for available provider plugins...
+
+Provider "awss" not available for installation.
+
+A provider named "awss" could not be found in the Terraform Registry.
+`,
+		ExitCode: 1,
+	}
+
+	InitRegistryUnreachable = Response{
+		Output: `
+Initializing the backend...
+
+Initializing provider plugins...
+- Checking for available provider plugins...
+
+Registry service unreachable.
+
+This may indicate a network issue, or an issue with the requested Terraform Registry.
+`,
+		ExitCode: 1,
+	}
+
+	PlanNoChanges = Response{
+		Output: `
+No changes. Infrastructure is up-to-date.
+
+This means that Terraform did not detect any differences between your
+configuration and real physical resources that exist.
+`,
+	}
+
+	PlanChanges = Response{
+		Output: `
+An execution plan has been generated and is shown below.
+Resource actions are indicated with the following symbols:
+  + create
+
+Terraform will perform the following actions:
+
+  # aws_s3_bucket.logs will be created
+  + resource "aws_s3_bucket" "logs" {
+      + bucket = "logs"
+      + id     = (known after apply)
+    }
+
+Plan: 1 to add, 0 to change, 0 to destroy.
+`,
+	}
+
+	PlanInvalidResourceType = Response{
+		Output: `
+Error: Invalid resource type
+
+  on main.tf line 1, in resource "aws_s3_buckett" "logs":
+   1: resource "aws_s3_buckett" "logs" {
+
+The provider provider.aws does not support resource type
+"aws_s3_buckett".
+`,
+		ExitCode: 1,
+	}
+
+	StateLocked = Response{
+		Output: `
+Error: Error acquiring the state lock
+
+Error message: ConditionalCheckFailedException: The conditional request failed
+Lock Info:
+  ID:        8e2d3c5a-1b7f-4c2e-9a61-0f3d5e7b9c12
+  Path:      terraform-state/prod/terraform.tfstate
+  Operation: OperationTypeApply
+  Who:       ci@runner-42
+  Version:   0.12.24
+  Created:   2020-05-04 10:15:42.123456 +0000 UTC
+  Info:      
+
+Terraform acquires a state lock to protect the state from being written
+by multiple users at the same time.
+`,
+		ExitCode: 1,
+	}
+
+	ApplySuccess = Response{
+		Output: `
+aws_s3_bucket.logs: Creating...
+aws_s3_bucket.logs: Creation complete after 2s [id=logs]
+
+Apply complete! Resources: 1 added, 0 changed, 0 destroyed.
+`,
+	}
+
+	ApplyThrottled = Response{
+		Output: `
+aws_s3_bucket.logs: Creating...
+
+Error: Error creating S3 bucket: Throttling: Rate exceeded
+
+  on main.tf line 1, in resource "aws_s3_bucket" "logs":
+   1: resource "aws_s3_bucket" "logs" {
+`,
+		ExitCode: 1,
+	}
+
+	ApplyAlreadyExists = Response{
+		Output: `
+aws_s3_bucket.logs: Creating...
+
+Error: Error creating S3 bucket: BucketAlreadyExists: The requested bucket name is not available
+
+  on main.tf line 1, in resource "aws_s3_bucket" "logs":
+   1: resource "aws_s3_bucket" "logs" {
+`,
+		ExitCode: 1,
+	}
+
+	ApplyApprovalPrompt = Response{
+		Output: `
+Plan: 1 to add, 0 to change, 0 to destroy.
+
+Do you want to perform these actions?
+  Terraform will perform the actions described above.
+  Only 'yes' will be accepted to approve.
+
+  Enter a value: `,
+		AfterPrompt: `
+aws_s3_bucket.logs: Creating...
+aws_s3_bucket.logs: Creation complete after 2s [id=logs]
+
+Apply complete! Resources: 1 added, 0 changed, 0 destroyed.
+`,
+	}
+
+	ShowPlan = Response{
+		Output: `{"format_version":"0.1","terraform_version":"0.12.24",` +
+			`"planned_values":{"root_module":{"resources":[{"address":"aws_s3_bucket.logs","mode":"managed",` +
+			`"type":"aws_s3_bucket","name":"logs","provider_name":"aws","schema_version":0,"values":{"bucket":"logs"}}]}},` +
+			`"resource_changes":[{"address":"aws_s3_bucket.logs","mode":"managed","type":"aws_s3_bucket","name":"logs",` +
+			`"provider_name":"aws","change":{"actions":["create"],"before":null,"after":{"bucket":"logs"},` +
+			`"after_unknown":{"id":true}}}]}` + "\n",
+	}
+)
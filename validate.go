@@ -0,0 +1,122 @@
+package terralib
+
+import (
+	"encoding/json"
+)
+
+// Exported error codes
+const (
+	ErrInvalidConfiguration     string = "errInvalidConfiguration"
+	ErrValidateOutputUnreadable string = "errValidateOutputUnreadable"
+	ErrValidateDefault          string = "errValidateDefault"
+)
+
+var validateClassifiers = []Classifier{
+	defaultClassifier(ErrValidateDefault, CommandValidate),
+}
+
+// ValidateOutput represents the output of the validate command
+type ValidateOutput struct {
+	Raw           string       `json:"-"`
+	FormatVersion string       `json:"format_version,omitempty"`
+	Valid         bool         `json:"valid"`
+	ErrorCount    int          `json:"error_count"`
+	WarningCount  int          `json:"warning_count"`
+	Diagnostics   []Diagnostic `json:"diagnostics"`
+}
+
+// Diagnostic represents an error or warning about the configuration
+type Diagnostic struct {
+	Severity string           `json:"severity"`
+	Summary  string           `json:"summary"`
+	Detail   string           `json:"detail,omitempty"`
+	Range    *DiagnosticRange `json:"range,omitempty"`
+}
+
+// DiagnosticRange represents the part of a configuration file a diagnostic
+// refers to
+type DiagnosticRange struct {
+	Filename string         `json:"filename"`
+	Start    SourcePosition `json:"start"`
+	End      SourcePosition `json:"end"`
+}
+
+// SourcePosition represents a position in a configuration file
+type SourcePosition struct {
+	Line   int `json:"line"`
+	Column int `json:"column"`
+	Byte   int `json:"byte"`
+}
+
+// ValidateError represents an error on the Validate command
+type ValidateError struct {
+	Reason      string
+	Code        string
+	Diagnostics []Diagnostic
+}
+
+func (e ValidateError) Error() string {
+	return e.Code
+}
+
+// Validate executes the 'terraform validate' command. The configuration is
+// not valid when it fails with ErrInvalidConfiguration. It fails with
+// ErrValidateOutputUnreadable when the JSON printed by terraform cannot be
+// read, rather than returning an output that is not valid with no error
+func (t *Terralib) Validate(options []string) (validateOutput ValidateOutput, err error) {
+	options = append([]string{"-no-color", "-json"}, options...)
+	if err := t.beforeCommand(CommandValidate, options); err != nil {
+		return ValidateOutput{}, err
+	}
+	defer func() {
+		t.afterCommand(CommandValidate, options, validateOutput, err)
+	}()
+	result, match, _ := t.executeResult(CommandValidate, options, nil)
+	validateOutput, validateError := parseValidateOutput(resultStdout(result))
+	validateOutput.Raw = string(result.Output)
+	// An error classified in the output explains an output that is not JSON
+	if match != nil {
+		if e, ok := validateError.(ValidateError); !ok || e.Code != ErrInvalidConfiguration {
+			validateError = validateErrorFrom(match)
+		}
+	}
+	return validateOutput, validateError
+}
+
+// parseValidateOutput reads the JSON printed by the validate command
+func parseValidateOutput(stdout []byte) (ValidateOutput, error) {
+	validateOutput := ValidateOutput{Raw: string(stdout)}
+	if err := json.Unmarshal(stdout, &validateOutput); err != nil {
+		return validateOutput, ValidateError{
+			Reason: "The output of validate could not be read: " + err.Error(),
+			Code:   ErrValidateOutputUnreadable,
+		}
+	}
+	if validateOutput.Valid {
+		return validateOutput, nil
+	}
+	var errors []Diagnostic
+	for _, diagnostic := range validateOutput.Diagnostics {
+		if diagnostic.Severity == "error" {
+			errors = append(errors, diagnostic)
+		}
+	}
+	validateError := ValidateError{
+		Code:        ErrInvalidConfiguration,
+		Diagnostics: errors,
+	}
+	if len(errors) > 0 {
+		validateError.Reason = errors[0].Summary
+	}
+	return validateOutput, validateError
+}
+
+func validateErrorFrom(match *Match) error {
+	if match == nil {
+		return nil
+	}
+	return ValidateError{
+		Reason: match.Reason,
+		Code:   match.Code,
+	}
+}
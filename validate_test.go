@@ -0,0 +1,63 @@
+package terralib
+
+import (
+	"context"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+const validateOutputValidTest string = `{
+  "format_version": "1.0",
+  "valid": true,
+  "error_count": 0,
+  "warning_count": 0,
+  "diagnostics": []
+}`
+
+// validateExecutor returns an executor printing stdout and stderr on validate
+func validateExecutor(stdout string, stderr string, exitCode int) Executor {
+	return executorFunc(func(ctx context.Context, inv Invocation) (Result, error) {
+		return Result{
+			Output:   []byte(stderr + stdout),
+			Stdout:   []byte(stdout),
+			Stderr:   []byte(stderr),
+			ExitCode: exitCode,
+		}, nil
+	})
+}
+
+func TestValidateStderr(t *testing.T) {
+	tf := Terralib{
+		Executor: validateExecutor(validateOutputValidTest, "Warning: the plugin cache is not writable\n", 0),
+	}
+	output, err := tf.Validate(nil)
+	if err != nil || !output.Valid {
+		t.Errorf("Got: %+v, %v, Expected a valid configuration", output, err)
+	}
+}
+
+func TestValidateUnreadableOutput(t *testing.T) {
+	tf := Terralib{
+		Executor: validateExecutor("Success! The configuration is valid.\n", "", 0),
+	}
+	_, err := tf.Validate(nil)
+	validateErr, ok := err.(ValidateError)
+	if !ok || validateErr.Code != ErrValidateOutputUnreadable {
+		t.Errorf("Got: %v, Expected: %s", err, ErrValidateOutputUnreadable)
+	}
+}
+
+func TestValidateFailed(t *testing.T) {
+	tf := Terralib{
+		Executor: validateExecutor("", "\nError: Could not load plugin\n", 1),
+	}
+	_, err := tf.Validate(nil)
+	expected := ValidateError{
+		Reason: "Could not load plugin",
+		Code:   ErrValidateDefault,
+	}
+	if !cmp.Equal(err, expected) {
+		t.Errorf("Got: %+v, Expected: %+v", err, expected)
+	}
+}
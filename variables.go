@@ -0,0 +1,122 @@
+package terralib
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"sort"
+	"strings"
+)
+
+// Exported error codes
+const (
+	ErrMissingVariable      string = "errMissingVariable"
+	ErrInvalidVariableValue string = "errInvalidVariableValue"
+	ErrUndeclaredVariable   string = "errUndeclaredVariable"
+)
+
+var variableClassifiers = []Classifier{
+	NewClassifier(ErrMissingVariable, `Error: (?P<reason>No value for required variable)`,
+		CommandPlan, CommandApply, CommandDestroy),
+	NewClassifier(ErrInvalidVariableValue, `Error: (?P<reason>Invalid value for (?:input )?variable)`,
+		CommandPlan, CommandApply, CommandDestroy),
+	NewClassifier(ErrUndeclaredVariable, `Error: (?P<reason>Value for undeclared variable)`,
+		CommandPlan, CommandApply, CommandDestroy),
+}
+
+// isVariableError tells whether an error code refers to an input variable
+func isVariableError(code string) bool {
+	return code == ErrMissingVariable || code == ErrInvalidVariableValue || code == ErrUndeclaredVariable
+}
+
+// inputOptions adds -input=false before the options, unless
+// Terralib.AllowInput is set or they set -input already
+func (t *Terralib) inputOptions(options []string) []string {
+	if t.AllowInput {
+		return options
+	}
+	for _, option := range options {
+		if option == "-input" || strings.HasPrefix(option, "-input=") {
+			return options
+		}
+	}
+	return append([]string{"-input=false"}, options...)
+}
+
+// Ways of passing Terralib.Variables to terraform
+const (
+	// VariablesFile writes them to a temporary .tfvars.json file
+	VariablesFile string = "file"
+	// VariablesEnv sets them as TF_VAR_ environment variables
+	VariablesEnv string = "env"
+)
+
+// WithVariables returns a copy of the Terralib passing the given variables
+// instead of Variables, to run a command with its own variables while the
+// Terralib is shared
+func (t *Terralib) WithVariables(variables map[string]interface{}) *Terralib {
+	c := *t
+	c.Variables = variables
+	return &c
+}
+
+// variableOptions returns the options and environment variables passing
+// Terralib.Variables, along with a function removing the files written
+func (t *Terralib) variableOptions() ([]string, []string, func(), error) {
+	cleanup := func() {}
+	if len(t.Variables) == 0 {
+		return nil, nil, cleanup, nil
+	}
+	switch t.VariablesAs {
+	case VariablesEnv:
+		env, err := variablesEnv(t.Variables)
+		return nil, env, cleanup, err
+	case VariablesFile, "":
+	default:
+		return nil, nil, cleanup, fmt.Errorf("Unknown way of passing variables %q", t.VariablesAs)
+	}
+	content, err := json.Marshal(t.Variables)
+	if err != nil {
+		return nil, nil, cleanup, fmt.Errorf("Could not encode variables: %v", err)
+	}
+	// TempFile creates the file with mode 0600
+	f, err := ioutil.TempFile("", "terralib-*.tfvars.json")
+	if err != nil {
+		return nil, nil, cleanup, err
+	}
+	cleanup = func() {
+		os.Remove(f.Name())
+	}
+	_, err = f.Write(content)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return nil, nil, cleanup, err
+	}
+	return []string{"-var-file=" + f.Name()}, nil, cleanup, nil
+}
+
+// variablesEnv encodes variables as TF_VAR_ environment variables. Strings are
+// passed as they are, other values as JSON, which terraform parses as HCL
+func variablesEnv(variables map[string]interface{}) ([]string, error) {
+	names := make([]string, 0, len(variables))
+	for name := range variables {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	env := make([]string, 0, len(names))
+	for _, name := range names {
+		value, ok := variables[name].(string)
+		if !ok {
+			encoded, err := json.Marshal(variables[name])
+			if err != nil {
+				return nil, fmt.Errorf("Could not encode variable %s: %v", name, err)
+			}
+			value = string(encoded)
+		}
+		env = append(env, "TF_VAR_"+name+"="+value)
+	}
+	return env, nil
+}
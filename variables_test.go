@@ -0,0 +1,177 @@
+package terralib
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+type subnetTest struct {
+	CIDR   string            `json:"cidr_block"`
+	Public bool              `json:"public"`
+	Tags   map[string]string `json:"tags,omitempty"`
+}
+
+var variablesTest = map[string]interface{}{
+	"region":   "eu-west-1",
+	"replicas": 3,
+	"enabled":  true,
+	"zones":    []string{"a", "b"},
+	"subnets":  []subnetTest{{CIDR: "10.0.0.0/24", Public: true, Tags: map[string]string{"tier": "web"}}},
+	"password": `p@ss "word"`,
+}
+
+func TestVariablesFile(t *testing.T) {
+	var args []string
+	var content []byte
+	var mode os.FileMode
+	tf := Terralib{
+		Variables: variablesTest,
+		Executor: executorFunc(func(ctx context.Context, inv Invocation) (Result, error) {
+			args = inv.Args
+			file := strings.TrimPrefix(inv.Args[1], "-var-file=")
+			content, _ = ioutil.ReadFile(file)
+			if info, err := os.Stat(file); err == nil {
+				mode = info.Mode()
+			}
+			if len(inv.Env) > 0 {
+				t.Errorf("Got: %v, Expected no environment variables", inv.Env)
+			}
+			return Result{}, nil
+		}),
+	}
+	if _, err := tf.Plan([]string{"-no-color"}); err != nil {
+		t.Fatal(err)
+	}
+	file := strings.TrimPrefix(args[1], "-var-file=")
+	if !strings.HasSuffix(file, ".tfvars.json") {
+		t.Errorf("Got: %v, Expected a -var-file option", args)
+	}
+	expected := `{"enabled":true,"password":"p@ss \"word\"","region":"eu-west-1","replicas":3,` +
+		`"subnets":[{"cidr_block":"10.0.0.0/24","public":true,"tags":{"tier":"web"}}],"zones":["a","b"]}`
+	if string(content) != expected {
+		t.Errorf("Got: %s, Expected: %s", content, expected)
+	}
+	if mode != 0600 {
+		t.Errorf("Got: %v, Expected the variables file to be private", mode)
+	}
+	if _, err := os.Stat(file); !os.IsNotExist(err) {
+		t.Errorf("Expected the variables file to be removed")
+	}
+}
+
+func TestWithVariables(t *testing.T) {
+	var contents []string
+	tf := &Terralib{
+		Variables: map[string]interface{}{"region": "eu-west-1"},
+		Executor: executorFunc(func(ctx context.Context, inv Invocation) (Result, error) {
+			content, _ := ioutil.ReadFile(strings.TrimPrefix(inv.Args[1], "-var-file="))
+			contents = append(contents, string(content))
+			return Result{}, nil
+		}),
+	}
+	if _, err := tf.WithVariables(map[string]interface{}{"region": "us-east-1"}).Plan(nil); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := tf.Plan(nil); err != nil {
+		t.Fatal(err)
+	}
+	expected := []string{`{"region":"us-east-1"}`, `{"region":"eu-west-1"}`}
+	if !cmp.Equal(contents, expected) {
+		t.Errorf("Got: %v, Expected: %v", contents, expected)
+	}
+}
+
+func TestVariablesEnv(t *testing.T) {
+	var env []string
+	var args []string
+	tf := Terralib{
+		Variables:   variablesTest,
+		VariablesAs: VariablesEnv,
+		Executor: executorFunc(func(ctx context.Context, inv Invocation) (Result, error) {
+			env = inv.Env
+			args = inv.Args
+			return Result{}, nil
+		}),
+	}
+	if _, err := tf.Destroy([]string{"-auto-approve"}); err != nil {
+		t.Fatal(err)
+	}
+	expected := []string{
+		"TF_VAR_enabled=true",
+		`TF_VAR_password=p@ss "word"`,
+		"TF_VAR_region=eu-west-1",
+		"TF_VAR_replicas=3",
+		`TF_VAR_subnets=[{"cidr_block":"10.0.0.0/24","public":true,"tags":{"tier":"web"}}]`,
+		`TF_VAR_zones=["a","b"]`,
+	}
+	if !cmp.Equal(env, expected) {
+		t.Errorf("Got: %v, Expected: %v", env, expected)
+	}
+	if !cmp.Equal(args, []string{"destroy", "-input=false", "-auto-approve"}) {
+		t.Errorf("Got: %v, Expected the variables not to be passed as options", args)
+	}
+}
+
+func TestVariablesApplyPlanFile(t *testing.T) {
+	var inv Invocation
+	tf := Terralib{
+		Variables: variablesTest,
+		Executor: executorFunc(func(ctx context.Context, i Invocation) (Result, error) {
+			inv = i
+			return Result{}, nil
+		}),
+	}
+	if _, err := tf.Apply([]string{"plan.tfplan"}); err != nil {
+		t.Fatal(err)
+	}
+	if !cmp.Equal(inv.Args, []string{"apply", "-input=false", "plan.tfplan"}) || len(inv.Env) > 0 {
+		t.Errorf("Got: %+v, Expected no variables along a plan file", inv)
+	}
+	// The values of -target and -var are not plan files
+	tests := [][]string{
+		{"-auto-approve", "-target", "aws_instance.x"},
+		{"-auto-approve", "-var", "a=b"},
+	}
+	for _, options := range tests {
+		if _, err := tf.Apply(options); err != nil {
+			t.Fatal(err)
+		}
+		if len(inv.Args) < 3 || !strings.HasPrefix(inv.Args[1], "-var-file=") {
+			t.Errorf("Got: %+v, Expected the variables to be passed along %v", inv.Args, options)
+		}
+	}
+}
+
+func TestVariablesInvalid(t *testing.T) {
+	tf := Terralib{
+		Variables: map[string]interface{}{"callback": func() {}},
+	}
+	_, err := tf.Plan(nil)
+	if err == nil || !strings.Contains(err.Error(), "Could not encode variables") {
+		t.Errorf("Got: %v, Expected an encoding error", err)
+	}
+}
+
+func TestInputOptions(t *testing.T) {
+	tests := []struct {
+		allowInput bool
+		options    []string
+		expected   []string
+	}{
+		{false, []string{"-no-color", "plan.tfplan"}, []string{"-input=false", "-no-color", "plan.tfplan"}},
+		{false, []string{"-input=true"}, []string{"-input=true"}},
+		{true, []string{"-no-color"}, []string{"-no-color"}},
+	}
+	for _, test := range tests {
+		tf := Terralib{AllowInput: test.allowInput}
+		got := tf.inputOptions(test.options)
+		if !cmp.Equal(got, test.expected) {
+			t.Errorf("Got: %v, Expected: %v", got, test.expected)
+		}
+	}
+}